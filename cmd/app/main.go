@@ -22,19 +22,47 @@ func main() {
 		upload.
 			GET("/upload", handlers.UploadVideoHandler)
 
-		video := api.Group("/video/{videoId}")
-		video.
+		tus := api.Group("/upload/tus")
+		tus.
+			OPTIONS("", handlers.TusOptionsHandler).
+			Bind(handlers.TusOptions())
+		tus.
 			Group("").
 			Bind(apis.RequireAuth()).
-			POST("/update", handlers.UpdateVideoHandler)
-		video.
-			Group("").
-			Bind(middleware.AuthorizeGet()).
-			GET("/stream", handlers.ServeVideoHandler)
+			POST("", handlers.TusCreateHandler)
+		tusUpload := tus.Group("/{videoId}").Bind(apis.RequireAuth(), apis.BodyLimit(0))
+		tusUpload.HEAD("", handlers.TusHeadHandler)
+		tusUpload.PATCH("", handlers.TusPatchHandler)
+		tusUpload.DELETE("", handlers.TusDeleteHandler)
+
+		video := api.Group("/video/{videoId}")
+		videoAuth := video.Group("").Bind(apis.RequireAuth())
+		videoAuth.POST("/update", handlers.UpdateVideoHandler)
+		videoAuth.GET("/preview/candidates", handlers.PreviewCandidatesHandler)
+		videoAuth.POST("/preview", handlers.SetVideoPreviewHandler)
+		stream := video.Group("").Bind(middleware.AuthorizeGet())
+		stream.GET("/stream", handlers.ServeVideoHandler)
+		stream.GET("/hls/{path...}", handlers.ServeHLSHandler)
+		stream.GET("/dash/{path...}", handlers.ServeDASHHandler)
+		stream.GET("/waveform", handlers.ServeWaveformHandler)
+
+		api.Group("/user/{userId}").
+			Bind(apis.RequireAuth()).
+			GET("/usage", handlers.UserUsageHandler)
+
+		// the public playlists are exported to the guests as well
+		api.GET("/playlist/{playlistId}/export", handlers.ExportPlaylistHandler)
 
 		playlist := api.Group("/playlist").Bind(apis.RequireAuth())
 		playlist.POST("", handlers.CreatePlaylistHandler)
-		playlist.Group("/{playlistId}").POST("", handlers.UpdatePlaylistHandler)
+		playlistItem := playlist.Group("/{playlistId}")
+		playlistItem.POST("", handlers.UpdatePlaylistHandler)
+		playlistItem.POST("/videos", handlers.InsertPlaylistVideoHandler)
+		playlistItem.PUT("/videos", handlers.ReorderPlaylistHandler)
+		playlistItem.POST("/videos/move", handlers.MovePlaylistVideoHandler)
+		playlistItem.POST("/videos/remove", handlers.RemovePlaylistVideoHandler)
+		playlistItem.POST("/members", handlers.InvitePlaylistMemberHandler)
+		playlistItem.DELETE("/members/{userId}", handlers.RemovePlaylistMemberHandler)
 
 		return se.Next()
 	})
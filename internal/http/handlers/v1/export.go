@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"vhs/internal/vhs"
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	PlaylistExportM3U8 = "m3u8"
+	PlaylistExportXSPF = "xspf"
+	PlaylistExportJSON = "json"
+
+	M3U8ContentType = "audio/x-mpegurl"
+	XSPFContentType = "application/xspf+xml"
+)
+
+// ExportPlaylistHandler lists the videos of the playlist for the external players,
+// with the stream URLs, which can be played without the authorization header.
+// The guests get the public videos of the public playlist, the stream tokens are only made
+// for the authorized users and aren't accepted here, so they can't be used to renew themselves.
+func (h *Handlers) ExportPlaylistHandler(e *core.RequestEvent) error {
+	format := e.Request.URL.Query().Get("format")
+	if format == "" {
+		format = PlaylistExportM3U8
+	}
+	if format != PlaylistExportM3U8 && format != PlaylistExportXSPF && format != PlaylistExportJSON {
+		return e.BadRequestError("format must be one of m3u8, xspf or json", nil)
+	}
+
+	info, err := e.RequestInfo()
+	if err != nil {
+		return err
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	export, err := h.app.ExportPlaylist(playlistId, info)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, vhs.ErrPlaylistAccess) {
+		return e.NotFoundError("playlist not found", err)
+	} else if err != nil {
+		return e.InternalServerError("error while exporting playlist", err)
+	}
+
+	baseURL := requestBaseURL(e)
+	for _, item := range export.Items {
+		item.URL = baseURL + "/api/video/" + url.PathEscape(item.Id) + "/stream"
+		if item.Token != "" {
+			item.URL += "?token=" + url.QueryEscape(item.Token)
+		}
+	}
+
+	// the URLs with the token can't be cached longer than the token lives
+	e.Response.Header().Set("Cache-Control", "no-store")
+
+	switch format {
+	case PlaylistExportXSPF:
+		return exportXSPF(e, export)
+	case PlaylistExportJSON:
+		return e.JSON(http.StatusOK, export)
+	default:
+		return exportM3U8(e, export)
+	}
+}
+
+// requestBaseURL returns the scheme and the host the request was sent to.
+func requestBaseURL(e *core.RequestEvent) string {
+	scheme := "http"
+	if e.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := e.Request.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + e.Request.Host
+}
+
+func exportM3U8(e *core.RequestEvent, export *entities.PlaylistExport) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#PLAYLIST:" + m3u8Line(export.Name) + "\n")
+	for _, item := range export.Items {
+		// -1 is the unknown duration
+		duration := -1
+		if item.Duration > 0 {
+			duration = int(math.Ceil(item.Duration))
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", duration, m3u8Line(item.Title))
+		b.WriteString(item.URL + "\n")
+	}
+
+	return e.Blob(http.StatusOK, M3U8ContentType, []byte(b.String()))
+}
+
+// m3u8Line keeps the title on the line of its tag.
+func m3u8Line(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version int         `xml:"version,attr"`
+	Title   string      `xml:"title"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title"`
+	// Duration is in milliseconds
+	Duration int64 `xml:"duration,omitempty"`
+}
+
+func exportXSPF(e *core.RequestEvent, export *entities.PlaylistExport) error {
+	playlist := xspfPlaylist{
+		Version: 1,
+		Title:   export.Name,
+		Tracks:  make([]xspfTrack, len(export.Items)),
+	}
+	for i, item := range export.Items {
+		playlist.Tracks[i] = xspfTrack{
+			Location: item.URL,
+			Title:    item.Title,
+			Duration: int64(math.Round(item.Duration * 1000)),
+		}
+	}
+
+	b, err := xml.MarshalIndent(playlist, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return e.Blob(http.StatusOK, XSPFContentType, append([]byte(xml.Header), b...))
+}
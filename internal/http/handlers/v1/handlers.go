@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
 	"vhs/internal/vhs"
@@ -40,40 +41,57 @@ func (h *Handlers) UploadVideoHandler(e *core.RequestEvent) error {
 }
 
 func (h *Handlers) ServeVideoHandler(e *core.RequestEvent) error {
-	videoId := e.Request.PathValue("videoId")
-
-	info, err := e.RequestInfo()
+	video, err := findAccessibleVideo(e)
 	if err != nil {
 		return err
 	}
 
-	record, err := vhs.PocketBase.FindRecordById(entities.VideosCollection, videoId)
+	fs, err := vhs.PocketBase.NewFilesystem()
 	if err != nil {
 		return err
 	}
 
-	video := vhs.NewVideoFromRecord(record)
+	return fs.Serve(
+		e.Response,
+		e.Request,
+		video.BaseFilesPath()+"/"+video.Video(),
+		video.Name(),
+	)
+}
+
+// findAccessibleVideo returns the video of the request path
+// if it can be viewed by the requester or by the owner of its stream token.
+func findAccessibleVideo(e *core.RequestEvent) (vhs.Video, error) {
+	videoId := e.Request.PathValue("videoId")
 
-	canAccess, err := vhs.PocketBase.CanAccessRecord(record, info, record.Collection().ViewRule)
+	info, err := e.RequestInfo()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !canAccess {
-		return e.NotFoundError("video not found", nil)
+	// the stream token of the exported playlist is in the query instead of the auth token
+	if token := e.Request.URL.Query().Get("token"); e.Auth == nil && token != "" {
+		if auth, err := vhs.FindAuthRecordByStreamToken(token, videoId); err == nil {
+			info = info.Clone()
+			info.Auth = auth
+		}
 	}
 
-	fs, err := vhs.PocketBase.NewFilesystem()
+	record, err := vhs.PocketBase.FindRecordById(entities.VideosCollection, videoId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return fs.Serve(
-		e.Response,
-		e.Request,
-		record.BaseFilesPath()+"/"+video.Video(),
-		video.Name(),
-	)
+	canAccess, err := vhs.PocketBase.CanAccessRecord(record, info, record.Collection().ViewRule)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canAccess {
+		return nil, e.NotFoundError("video not found", nil)
+	}
+
+	return vhs.NewVideoFromRecord(record), nil
 }
 
 func (h *Handlers) UpdateVideoHandler(e *core.RequestEvent) error {
@@ -98,6 +116,65 @@ func (h *Handlers) UpdateVideoHandler(e *core.RequestEvent) error {
 	return nil
 }
 
+func (h *Handlers) PreviewCandidatesHandler(e *core.RequestEvent) error {
+	videoId := e.Request.PathValue("videoId")
+	candidates, err := h.app.PreviewCandidates(videoId, e.Auth.Id)
+	if err != nil {
+		return videoPreviewError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, candidates)
+}
+
+func (h *Handlers) SetVideoPreviewHandler(e *core.RequestEvent) error {
+	var data *dto.VideoPreviewRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.Time == nil && data.Candidate == "" {
+		return e.BadRequestError("either time or candidate is required", nil)
+	}
+
+	videoId := e.Request.PathValue("videoId")
+	err := h.app.SetVideoPreview(videoId, e.Auth.Id, dto.NewVideoPreview(data))
+	if err != nil {
+		return videoPreviewError(e, err)
+	}
+
+	return nil
+}
+
+func videoPreviewError(e *core.RequestEvent, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, vhs.ErrVideoAccess):
+		return e.NotFoundError("video not found", err)
+	case errors.Is(err, vhs.ErrPreviewTime),
+		errors.Is(err, vhs.ErrPreviewCandidate),
+		errors.Is(err, vhs.ErrPreviewAudio),
+		errors.Is(err, vhs.ErrVideoNotProcessed):
+		return e.BadRequestError(err.Error(), err)
+	default:
+		return e.InternalServerError("error while setting video preview", err)
+	}
+}
+
+// UserUsageHandler reports the storage used by the user, to the user and to the superusers only.
+func (h *Handlers) UserUsageHandler(e *core.RequestEvent) error {
+	userId := e.Request.PathValue("userId")
+	if e.Auth.Id != userId && !e.HasSuperuserAuth() {
+		return e.NotFoundError("user not found", nil)
+	}
+
+	usage, err := h.app.UserUsage(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e.NotFoundError("user not found", err)
+	} else if err != nil {
+		return e.InternalServerError("error while counting user usage", err)
+	}
+
+	return e.JSON(http.StatusOK, usage)
+}
+
 func (h *Handlers) CreatePlaylistHandler(e *core.RequestEvent) error {
 	var data *dto.PlaylistCreateRequest
 	if err := e.BindBody(&data); err != nil {
@@ -106,7 +183,7 @@ func (h *Handlers) CreatePlaylistHandler(e *core.RequestEvent) error {
 
 	err := h.app.CreatePlaylist(e.Auth.Id, dto.NewPlaylistCreate(data))
 	if err != nil {
-		return e.InternalServerError("error while creating playlist", err)
+		return playlistError(e, err, "error while creating playlist")
 	}
 
 	return nil
@@ -121,8 +198,133 @@ func (h *Handlers) UpdatePlaylistHandler(e *core.RequestEvent) error {
 	playlistId := e.Request.PathValue("playlistId")
 	err := h.app.UpdatePlaylist(playlistId, e.Auth.Id, dto.NewPlaylistUpdate(data))
 	if err != nil {
-		return e.InternalServerError("error while updating playlist", err)
+		return playlistError(e, err, "error while updating playlist")
 	}
 
 	return nil
 }
+
+func (h *Handlers) InsertPlaylistVideoHandler(e *core.RequestEvent) error {
+	var data *dto.PlaylistInsertRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.Video == "" || data.Version == nil {
+		return e.BadRequestError("video and version are required", nil)
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	order, err := h.app.InsertPlaylistVideo(playlistId, e.Auth.Id, dto.NewPlaylistInsert(data))
+	if err != nil {
+		return playlistError(e, err, "error while changing playlist order")
+	}
+
+	return e.JSON(http.StatusOK, order)
+}
+
+func (h *Handlers) MovePlaylistVideoHandler(e *core.RequestEvent) error {
+	var data *dto.PlaylistMoveRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.Version == nil {
+		return e.BadRequestError("version is required", nil)
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	order, err := h.app.MovePlaylistVideo(playlistId, e.Auth.Id, dto.NewPlaylistMove(data))
+	if err != nil {
+		return playlistError(e, err, "error while changing playlist order")
+	}
+
+	return e.JSON(http.StatusOK, order)
+}
+
+func (h *Handlers) ReorderPlaylistHandler(e *core.RequestEvent) error {
+	var data *dto.PlaylistReorderRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.Version == nil {
+		return e.BadRequestError("version is required", nil)
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	order, err := h.app.ReorderPlaylist(playlistId, e.Auth.Id, dto.NewPlaylistReorder(data))
+	if err != nil {
+		return playlistError(e, err, "error while changing playlist order")
+	}
+
+	return e.JSON(http.StatusOK, order)
+}
+
+func (h *Handlers) RemovePlaylistVideoHandler(e *core.RequestEvent) error {
+	var data *dto.PlaylistRemoveRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.Video == "" || data.Version == nil {
+		return e.BadRequestError("video and version are required", nil)
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	order, err := h.app.RemovePlaylistVideo(playlistId, e.Auth.Id, dto.NewPlaylistRemove(data))
+	if err != nil {
+		return playlistError(e, err, "error while changing playlist order")
+	}
+
+	return e.JSON(http.StatusOK, order)
+}
+
+func (h *Handlers) InvitePlaylistMemberHandler(e *core.RequestEvent) error {
+	var data *dto.PlaylistInviteRequest
+	if err := e.BindBody(&data); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	if data.User == "" || data.Role == "" {
+		return e.BadRequestError("user and role are required", nil)
+	}
+
+	playlistId := e.Request.PathValue("playlistId")
+	err := h.app.InvitePlaylistMember(playlistId, e.Auth.Id, dto.NewPlaylistInvite(data))
+	if err != nil {
+		return playlistError(e, err, "error while inviting playlist member")
+	}
+
+	return nil
+}
+
+func (h *Handlers) RemovePlaylistMemberHandler(e *core.RequestEvent) error {
+	playlistId := e.Request.PathValue("playlistId")
+	userId := e.Request.PathValue("userId")
+	err := h.app.RemovePlaylistMember(playlistId, e.Auth.Id, userId)
+	if err != nil {
+		return playlistError(e, err, "error while removing playlist member")
+	}
+
+	return nil
+}
+
+func playlistError(e *core.RequestEvent, err error, message string) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, vhs.ErrPlaylistAccess):
+		return e.NotFoundError("playlist not found", err)
+	case errors.Is(err, vhs.ErrPlaylistMember):
+		return e.NotFoundError("playlist member not found", err)
+	case errors.Is(err, vhs.ErrPlaylistRole):
+		return e.ForbiddenError(err.Error(), err)
+	case errors.Is(err, vhs.ErrPlaylistVersion):
+		return e.Error(http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, vhs.ErrPlaylistIndex),
+		errors.Is(err, vhs.ErrPlaylistDuplicate),
+		errors.Is(err, vhs.ErrPlaylistReorder),
+		errors.Is(err, vhs.ErrPlaylistVideo),
+		errors.Is(err, vhs.ErrPlaylistMemberUser),
+		errors.Is(err, vhs.ErrPlaylistMemberRole),
+		errors.Is(err, vhs.ErrPlaylistSmart),
+		errors.Is(err, vhs.ErrPlaylistFilter):
+		return e.BadRequestError(err.Error(), err)
+	default:
+		return e.InternalServerError(message, err)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"vhs/internal/vhs"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	HLSPlaylistContentType  = "application/vnd.apple.mpegurl"
+	HLSSegmentContentType   = "video/mp2t"
+	DASHManifestContentType = "application/dash+xml"
+	DASHSegmentContentType  = "video/iso.segment"
+)
+
+func (h *Handlers) ServeHLSHandler(e *core.RequestEvent) error {
+	video, err := findAccessibleVideo(e)
+	if err != nil {
+		return err
+	}
+
+	name, ok := CleanStreamPath(e.Request.PathValue("path"))
+	if !ok {
+		return e.NotFoundError("file not found", nil)
+	}
+
+	key := video.BaseFilesPath() + "/" + vhs.HLSFilesDir + "/" + name
+
+	if path.Ext(name) == ".m3u8" {
+		return serveHLSPlaylist(e, key)
+	}
+
+	return serveStreamFile(e, key, name)
+}
+
+func (h *Handlers) ServeDASHHandler(e *core.RequestEvent) error {
+	video, err := findAccessibleVideo(e)
+	if err != nil {
+		return err
+	}
+
+	name, ok := CleanStreamPath(e.Request.PathValue("path"))
+	if !ok {
+		return e.NotFoundError("file not found", nil)
+	}
+
+	key := video.BaseFilesPath() + "/" + vhs.DASHFilesDir + "/" + name
+
+	if path.Ext(name) == ".mpd" {
+		return serveDASHManifest(e, key)
+	}
+
+	return serveStreamFile(e, key, name)
+}
+
+func (h *Handlers) ServeWaveformHandler(e *core.RequestEvent) error {
+	video, err := findAccessibleVideo(e)
+	if err != nil {
+		return err
+	}
+
+	if video.Waveform() == "" {
+		return e.NotFoundError("waveform not found", nil)
+	}
+
+	return serveStreamFile(e, video.BaseFilesPath()+"/"+video.Waveform(), "waveform.json")
+}
+
+// CleanStreamPath validates the relative path of a streaming file,
+// so it can't point out of the streaming directory of the video.
+func CleanStreamPath(name string) (string, bool) {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return "", false
+	}
+
+	name = path.Clean(name)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+
+	return name, true
+}
+
+func serveStreamFile(e *core.RequestEvent, key, name string) error {
+	fs, err := vhs.PocketBase.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	if exists, err := fs.Exists(key); err != nil || !exists {
+		return e.NotFoundError("file not found", err)
+	}
+
+	switch path.Ext(name) {
+	case ".ts":
+		e.Response.Header().Set("Content-Type", HLSSegmentContentType)
+	case ".m4s":
+		e.Response.Header().Set("Content-Type", DASHSegmentContentType)
+	default:
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			e.Response.Header().Set("Content-Type", contentType)
+		}
+	}
+
+	return fs.Serve(e.Response, e.Request, key, path.Base(name))
+}
+
+// serveHLSPlaylist serves the playlist, passing the access token
+// of the request to the URIs of the playlist, so the player can
+// request the nested playlists and segments of non-public videos.
+func serveHLSPlaylist(e *core.RequestEvent, key string) error {
+	fs, err := vhs.PocketBase.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	r, err := fs.GetReader(key)
+	if err != nil {
+		return e.NotFoundError("file not found", err)
+	}
+	defer r.Close()
+
+	token := e.Request.URL.Query().Get("token")
+
+	var b bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if token != "" && line != "" && !strings.HasPrefix(line, "#") {
+			line += "?token=" + url.QueryEscape(token)
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	e.Response.Header().Set("Cache-Control", "no-cache")
+
+	return e.Blob(http.StatusOK, HLSPlaylistContentType, b.Bytes())
+}
+
+// serveDASHManifest serves the manifest, passing the access token
+// of the request to the segment templates of the manifest.
+func serveDASHManifest(e *core.RequestEvent, key string) error {
+	fs, err := vhs.PocketBase.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	r, err := fs.GetReader(key)
+	if err != nil {
+		return e.NotFoundError("file not found", err)
+	}
+	defer r.Close()
+
+	manifest, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if token := e.Request.URL.Query().Get("token"); token != "" {
+		manifest = bytes.ReplaceAll(
+			manifest,
+			[]byte(`.m4s"`),
+			[]byte(`.m4s?token=`+url.QueryEscape(token)+`"`),
+		)
+	}
+
+	e.Response.Header().Set("Cache-Control", "no-cache")
+
+	return e.Blob(http.StatusOK, DASHManifestContentType, manifest)
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"vhs/internal/vhs"
+	"vhs/pkg/checksum"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Tus 1.0 protocol, see https://tus.io/protocols/resumable-upload
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,termination,checksum"
+	TusBasePath   = "/api/upload/tus"
+
+	tusStatusChecksumMismatch = 460
+)
+
+var tusExposedHeaders = strings.Join([]string{
+	"Location",
+	"Tus-Resumable",
+	"Tus-Version",
+	"Tus-Extension",
+	"Tus-Max-Size",
+	"Tus-Checksum-Algorithm",
+	"Upload-Offset",
+	"Upload-Length",
+	"Upload-Metadata",
+}, ",")
+
+// TusOptions sets the tus discovery headers before the CORS middleware,
+// which responds to the OPTIONS requests by itself.
+func (h *Handlers) TusOptions() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Id: "tusOptions",
+		Func: func(e *core.RequestEvent) error {
+			setTusOptionsHeaders(e.Response.Header())
+			return e.Next()
+		},
+		Priority: apis.DefaultCorsMiddlewarePriority - 1,
+	}
+}
+
+func (h *Handlers) TusOptionsHandler(e *core.RequestEvent) error {
+	setTusOptionsHeaders(e.Response.Header())
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+func (h *Handlers) TusCreateHandler(e *core.RequestEvent) error {
+	setTusHeaders(e.Response.Header())
+	if err := checkTusResumable(e); err != nil {
+		return err
+	}
+
+	size, err := strconv.Atoi(e.Request.Header.Get("Upload-Length"))
+	if err != nil || size <= 0 {
+		return e.BadRequestError("invalid Upload-Length header", err)
+	}
+	if size > vhs.MaxUploadSize {
+		return e.Error(http.StatusRequestEntityTooLarge, "upload is too large", nil)
+	}
+
+	metadata, err := parseTusMetadata(e.Request.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return e.BadRequestError("invalid Upload-Metadata header", err)
+	}
+
+	name := metadata["filename"]
+	if name == "" {
+		name = metadata["name"]
+	}
+
+	videoId, err := h.app.CreateUpload(&vhs.VideoUploadData{
+		Size:   size,
+		Name:   name,
+		UserId: e.Auth.Id,
+	})
+	switch {
+	case errors.Is(err, vhs.ErrUploadQuota):
+		return e.Error(http.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, vhs.ErrUploadLimit):
+		return e.TooManyRequestsError(err.Error(), nil)
+	case err != nil:
+		return e.InternalServerError("error while creating upload", err)
+	}
+
+	e.Response.Header().Set("Location", TusBasePath+"/"+videoId)
+
+	return e.NoContent(http.StatusCreated)
+}
+
+func (h *Handlers) TusHeadHandler(e *core.RequestEvent) error {
+	header := e.Response.Header()
+	setTusHeaders(header)
+	if err := checkTusResumable(e); err != nil {
+		return err
+	}
+
+	status, err := h.app.UploadStatus(e.Request.PathValue("videoId"), e.Auth.Id)
+	if err != nil {
+		return e.NotFoundError("upload not found", err)
+	}
+
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.Itoa(status.Offset))
+	header.Set("Upload-Length", strconv.Itoa(status.Size))
+
+	return e.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) TusPatchHandler(e *core.RequestEvent) error {
+	header := e.Response.Header()
+	setTusHeaders(header)
+	if err := checkTusResumable(e); err != nil {
+		return err
+	}
+
+	if e.Request.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		return e.Error(http.StatusUnsupportedMediaType, "invalid Content-Type header", nil)
+	}
+
+	offset, err := strconv.Atoi(e.Request.Header.Get("Upload-Offset"))
+	if err != nil || offset < 0 {
+		return e.BadRequestError("invalid Upload-Offset header", err)
+	}
+
+	verifier, err := parseTusChecksum(e.Request.Header.Get("Upload-Checksum"))
+	if err != nil {
+		return e.BadRequestError("invalid Upload-Checksum header", err)
+	}
+
+	offset, err = h.app.AppendUpload(e.Request.PathValue("videoId"), e.Auth.Id, offset, e.Request.Body, verifier)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, vhs.ErrUploadAccess):
+		return e.NotFoundError("upload not found", err)
+	case errors.Is(err, vhs.ErrUploadOffset):
+		return e.Error(http.StatusConflict, "upload offset mismatch", nil)
+	case errors.Is(err, vhs.ErrUploadInProgress):
+		return e.Error(http.StatusLocked, "upload is already in progress", nil)
+	case errors.Is(err, vhs.ErrUploadSize):
+		return e.Error(http.StatusRequestEntityTooLarge, "upload size exceeded", nil)
+	case errors.Is(err, vhs.ErrUploadQuota):
+		return e.Error(http.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, vhs.ErrUploadLimit):
+		return e.TooManyRequestsError(err.Error(), nil)
+	case errors.Is(err, checksum.ErrMismatch):
+		return e.Error(tusStatusChecksumMismatch, "checksum mismatch", nil)
+	case vhs.UploadErrorCode(err) == vhs.UploadErrorCodeUnsupported:
+		return e.Error(http.StatusUnsupportedMediaType, err.Error(), nil)
+	case err != nil:
+		return e.InternalServerError("error while uploading", err)
+	}
+
+	header.Set("Upload-Offset", strconv.Itoa(offset))
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+func (h *Handlers) TusDeleteHandler(e *core.RequestEvent) error {
+	setTusHeaders(e.Response.Header())
+	if err := checkTusResumable(e); err != nil {
+		return err
+	}
+
+	err := h.app.CancelUpload(e.Request.PathValue("videoId"), e.Auth.Id)
+	if errors.Is(err, vhs.ErrUploadInProgress) {
+		return e.Error(http.StatusLocked, "upload is already in progress", nil)
+	} else if err != nil {
+		return e.NotFoundError("upload not found", err)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+func setTusHeaders(header http.Header) {
+	header.Set("Tus-Resumable", TusVersion)
+	header.Set("Access-Control-Expose-Headers", tusExposedHeaders)
+}
+
+func setTusOptionsHeaders(header http.Header) {
+	setTusHeaders(header)
+	header.Set("Tus-Version", TusVersion)
+	header.Set("Tus-Extension", TusExtensions)
+	header.Set("Tus-Max-Size", strconv.Itoa(vhs.MaxUploadSize))
+	header.Set("Tus-Checksum-Algorithm", strings.Join(checksum.Algorithms(), ","))
+}
+
+func checkTusResumable(e *core.RequestEvent) error {
+	if e.Request.Header.Get("Tus-Resumable") != TusVersion {
+		e.Response.Header().Set("Tus-Version", TusVersion)
+		return e.Error(http.StatusPreconditionFailed, "unsupported tus version", nil)
+	}
+
+	return nil
+}
+
+// parseTusMetadata parses the Upload-Metadata header:
+// comma separated pairs of a key and an optional base64 encoded value.
+func parseTusMetadata(s string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if s == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata[key] = string(decoded)
+	}
+
+	return metadata, nil
+}
+
+// parseTusChecksum parses the Upload-Checksum header: an algorithm and a base64 encoded sum.
+func parseTusChecksum(s string) (*checksum.Verifier, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	algorithm, value, ok := strings.Cut(s, " ")
+	if !ok {
+		return nil, errors.New("missing checksum value")
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return checksum.NewVerifier(algorithm, sum)
+}
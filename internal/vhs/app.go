@@ -1,15 +1,33 @@
 package vhs
 
 import (
+	"io"
+	"vhs/internal/vhs/entities"
 	"vhs/internal/vhs/entities/dto"
+	"vhs/pkg/checksum"
 
 	"github.com/gorilla/websocket"
+	"github.com/pocketbase/pocketbase/core"
 )
 
 type App interface {
 	Start() error
 	UploadVideo(conn *websocket.Conn) error
+	CreateUpload(data *VideoUploadData) (string, error)
+	UploadStatus(id string, userId string) (*UploadStatus, error)
+	AppendUpload(id string, userId string, offset int, r io.Reader, verifier *checksum.Verifier) (int, error)
+	CancelUpload(id string, userId string) error
 	UpdateVideo(id string, userId string, data *dto.VideoUpdate) error
+	PreviewCandidates(id string, userId string) ([]*entities.PreviewCandidate, error)
+	SetVideoPreview(id string, userId string, data *dto.VideoPreview) error
+	UserUsage(id string) (*entities.UserUsage, error)
 	CreatePlaylist(userId string, data *dto.PlaylistCreate) error
 	UpdatePlaylist(id string, userId string, data *dto.PlaylistUpdate) error
+	InsertPlaylistVideo(id string, userId string, data *dto.PlaylistInsert) (*entities.PlaylistOrder, error)
+	RemovePlaylistVideo(id string, userId string, data *dto.PlaylistRemove) (*entities.PlaylistOrder, error)
+	MovePlaylistVideo(id string, userId string, data *dto.PlaylistMove) (*entities.PlaylistOrder, error)
+	ReorderPlaylist(id string, userId string, data *dto.PlaylistReorder) (*entities.PlaylistOrder, error)
+	InvitePlaylistMember(id string, userId string, data *dto.PlaylistInvite) error
+	RemovePlaylistMember(id string, userId string, memberId string) error
+	ExportPlaylist(id string, info *core.RequestInfo) (*entities.PlaylistExport, error)
 }
@@ -1,16 +1,22 @@
 package vhs
 
 import (
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"vhs/internal/vhs/entities"
 	"vhs/internal/vhs/entities/dto"
-	"vhs/internal/vhs/helper"
+	"vhs/pkg/checksum"
 	"vhs/pkg/collections"
+	"vhs/pkg/ffhelp"
 
 	"github.com/gorilla/websocket"
 	"github.com/ncruces/go-sqlite3/driver"
@@ -28,6 +34,9 @@ import (
 var (
 	PocketBase  *pocketbase.PocketBase
 	Collections *collections.Collections
+	Jobs        JobQueue
+	// FFmpeg runs the ffmpeg and ffprobe commands of the uploads and the processing.
+	FFmpeg = ffhelp.NewRunner()
 )
 
 type AppBase struct {
@@ -54,6 +63,9 @@ func New() App {
 		},
 	})
 	Collections = collections.NewCollections(PocketBase)
+	Config = LoadConfig(PocketBase.Logger())
+	FFmpeg = ffhelp.NewLimitedRunner(ffhelp.NewRunner(), Config.MaxFFmpegProcesses)
+	Jobs = NewJobQueue(PocketBase.Logger(), Config.ProcessingWorkers)
 
 	app := &AppBase{
 		logger: PocketBase.Logger(),
@@ -64,17 +76,41 @@ func New() App {
 	return app
 }
 
+type AppBaseMock struct {
+	Logger *slog.Logger
+}
+
+func NewAppMock(a *AppBaseMock) *AppBase {
+	return &AppBase{
+		logger: a.Logger,
+	}
+}
+
 func (a *AppBase) bindHooks() {
 	PocketBase.OnRecordCreate(entities.PlaylistsCollection).BindFunc(a.updatePlaylistPreview)
 	PocketBase.OnRecordUpdate(entities.PlaylistsCollection).BindFunc(a.updatePlaylistPreview)
 	PocketBase.OnRecordAfterUpdateSuccess(entities.VideosCollection).BindFunc(a.updatePlaylistPreviewFromVideo)
+	PocketBase.OnRecordAfterCreateSuccess(entities.VideosCollection).BindFunc(a.refreshSmartPlaylistPreviews)
+	PocketBase.OnRecordAfterUpdateSuccess(entities.VideosCollection).BindFunc(a.refreshSmartPlaylistPreviews)
+	PocketBase.OnRecordAfterDeleteSuccess(entities.VideosCollection).BindFunc(a.refreshSmartPlaylistPreviews)
 	PocketBase.OnRecordEnrich(entities.VideosCollection).BindFunc(a.enrichVideo)
+	PocketBase.OnRecordEnrich(entities.PlaylistsCollection).BindFunc(a.enrichPlaylist)
+	PocketBase.OnServe().BindFunc(a.startJobs)
+	PocketBase.Cron().MustAdd("uploadSessionsCleanup", "*/30 * * * *", a.CleanupUploadSessions)
 }
 
 func (a *AppBase) Start() error {
 	return PocketBase.Start()
 }
 
+func (a *AppBase) startJobs(e *core.ServeEvent) error {
+	if err := Jobs.Start(); err != nil {
+		return err
+	}
+
+	return e.Next()
+}
+
 func inspectRuntime() (withGoRun bool) {
 	if strings.HasPrefix(os.Args[0], os.TempDir()) {
 		// probably ran with go run
@@ -87,15 +123,24 @@ func inspectRuntime() (withGoRun bool) {
 }
 
 const (
+	UploadVideoMessageStart  = "start"
 	UploadVideoMessagePart   = "part"
 	UploadVideoMessageEnd    = "end"
 	UploadVideoMessageCancel = "cancel"
 	UploadVideoMessageError  = "error"
+
+	// UploadReadTimeout closes the connections which stopped sending data,
+	// so their upload sessions can be resumed from another connection.
+	UploadReadTimeout = time.Minute
+
+	// UploadChunkSize is the size of the parts a streamed upload body is written by.
+	UploadChunkSize = 1 << 20
 )
 
 func (a *AppBase) UploadVideo(c *websocket.Conn) error {
 	var (
 		v          VideoUploader
+		verifier   *checksum.Verifier
 		err        error
 		mt         int
 		message    []byte
@@ -108,6 +153,10 @@ func (a *AppBase) UploadVideo(c *websocket.Conn) error {
 	v = NewVideoUploader(a.logger)
 
 	for {
+		if err = c.SetReadDeadline(time.Now().Add(UploadReadTimeout)); err != nil {
+			break
+		}
+
 		mt, message, err = c.ReadMessage()
 		if err != nil {
 			resMessage = UploadVideoMessageError
@@ -116,17 +165,41 @@ func (a *AppBase) UploadVideo(c *websocket.Conn) error {
 
 		switch mt {
 		case websocket.TextMessage:
-			videoId, err = a.startUpload(message, v)
-			resMessage = UploadVideoMessagePart
-			res["videoId"] = videoId
+			var m *UploadMessage
+			if err = json.Unmarshal(message, &m); err != nil {
+				err = NewUploadError(UploadErrorCodeMessage, err)
+				break
+			}
+
+			switch m.Type {
+			case "", UploadVideoMessageStart:
+				videoId, err = a.startUpload(message, v)
+				resMessage = UploadVideoMessagePart
+				res["videoId"] = videoId
+			case UploadVideoMessagePart:
+				verifier, err = newPartVerifier(m)
+				resMessage = UploadVideoMessagePart
+			case UploadVideoMessageEnd:
+				err = a.finishUpload(m, v)
+				done = err == nil
+				resMessage = UploadVideoMessageEnd
+				res["videoId"] = videoId
+			case UploadVideoMessageCancel:
+				err = v.Cancel()
+				resMessage = UploadVideoMessageCancel
+			default:
+				err = NewUploadError(UploadErrorCodeMessage, fmt.Errorf("unknown message type %s", m.Type))
+			}
+			res["offset"] = v.Offset()
 			break
 		case websocket.BinaryMessage:
-			done, err = v.UploadPart(message)
-			if done {
-				resMessage = UploadVideoMessageEnd
-			} else {
-				resMessage = UploadVideoMessagePart
+			err = verifyPart(verifier, message)
+			verifier = nil
+			if err == nil {
+				_, err = v.UploadPart(message)
 			}
+			resMessage = UploadVideoMessagePart
+			res["offset"] = v.Offset()
 			break
 		case websocket.CloseMessage:
 			err = v.Cancel()
@@ -139,6 +212,7 @@ func (a *AppBase) UploadVideo(c *websocket.Conn) error {
 		if err != nil {
 			resMessage = UploadVideoMessageError
 			res["error"] = err.Error()
+			res["code"] = UploadErrorCode(err)
 		}
 
 		res["type"] = resMessage
@@ -152,6 +226,60 @@ func (a *AppBase) UploadVideo(c *websocket.Conn) error {
 
 	if done {
 		v.Done()
+	} else if suspendErr := v.Suspend(); suspendErr != nil {
+		a.logger.Error(
+			"error while suspending upload: "+suspendErr.Error(),
+			"videoId", videoId,
+		)
+	}
+
+	return err
+}
+
+// newPartVerifier returns the verifier of the next binary part
+// for the checksum announced by the "part" message.
+func newPartVerifier(m *UploadMessage) (*checksum.Verifier, error) {
+	if m.Algorithm != checksum.AlgorithmCRC32C && m.Algorithm != checksum.AlgorithmSHA256 {
+		return nil, NewUploadError(UploadErrorCodeMessage, fmt.Errorf("%w: %s", checksum.ErrUnsupportedAlgorithm, m.Algorithm))
+	}
+
+	sum, err := hex.DecodeString(m.Checksum)
+	if err != nil {
+		return nil, NewUploadError(UploadErrorCodeMessage, err)
+	}
+
+	return checksum.NewVerifier(m.Algorithm, sum)
+}
+
+func verifyPart(verifier *checksum.Verifier, p []byte) error {
+	if verifier == nil {
+		return nil
+	}
+
+	verifier.Write(p)
+	if err := verifier.Verify(); err != nil {
+		return ErrUploadChecksum
+	}
+
+	return nil
+}
+
+// finishUpload verifies the SHA-256 of the whole file sent with the "end" message.
+// If the file doesn't match it, the received bytes are discarded, but the session is kept,
+// so the client can send the file again.
+func (a *AppBase) finishUpload(m *UploadMessage, v VideoUploader) error {
+	if m.Checksum == "" {
+		return ErrUploadHashRequired
+	}
+
+	sum, err := hex.DecodeString(m.Checksum)
+	if err != nil {
+		return NewUploadError(UploadErrorCodeMessage, err)
+	}
+
+	err = v.Finish(sum)
+	if errors.Is(err, ErrUploadHash) {
+		return errors.Join(err, v.Truncate(0))
 	}
 
 	return err
@@ -172,7 +300,13 @@ func (a *AppBase) startUpload(message []byte, v VideoUploader) (string, error) {
 	}
 
 	data.UserId = record.Id
-	videoId, err := v.Start(data)
+
+	var videoId string
+	if data.VideoId != "" {
+		videoId, err = v.Resume(data)
+	} else {
+		videoId, err = v.Start(data)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -180,6 +314,177 @@ func (a *AppBase) startUpload(message []byte, v VideoUploader) (string, error) {
 	return videoId, nil
 }
 
+// CreateUpload starts a new upload session without writing any data to it.
+func (a *AppBase) CreateUpload(data *VideoUploadData) (string, error) {
+	v := NewVideoUploader(a.logger)
+
+	videoId, err := v.Start(data)
+	if err != nil {
+		return "", err
+	}
+
+	return videoId, v.Suspend()
+}
+
+func (a *AppBase) UploadStatus(id string, userId string) (*UploadStatus, error) {
+	session, err := NewUploadSessionOfUser(id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(session.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadStatus{
+		Name:   session.Name(),
+		Size:   session.Size(),
+		Offset: int(stat.Size()),
+	}, nil
+}
+
+// AppendUpload writes the body to the upload session starting from the offset,
+// which must match the number of bytes already received.
+// If the verifier is set and the body doesn't match its checksum, the body is discarded.
+// It returns the offset after the write.
+func (a *AppBase) AppendUpload(id string, userId string, offset int, r io.Reader, verifier *checksum.Verifier) (int, error) {
+	v := NewVideoUploader(a.logger)
+
+	_, err := v.Resume(&VideoUploadData{
+		VideoId: id,
+		UserId:  userId,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	done, err := a.appendUpload(v, offset, r, verifier)
+	if done && err == nil {
+		err = v.Finish(nil)
+		done = err == nil
+	}
+	if done {
+		v.Done()
+	} else if suspendErr := v.Suspend(); suspendErr != nil {
+		a.logger.Error(
+			"error while suspending upload: "+suspendErr.Error(),
+			"videoId", id,
+		)
+	}
+
+	return v.Offset(), err
+}
+
+func (a *AppBase) appendUpload(v VideoUploader, offset int, r io.Reader, verifier *checksum.Verifier) (bool, error) {
+	if v.Offset() != offset {
+		return false, ErrUploadOffset
+	}
+
+	if verifier != nil {
+		r = io.TeeReader(r, verifier)
+	}
+
+	var (
+		done    bool
+		err     error
+		readErr error
+		n       int
+		buff    = make([]byte, UploadChunkSize)
+	)
+	for readErr == nil {
+		n, readErr = io.ReadFull(r, buff)
+		if n > 0 {
+			if done, err = v.UploadPart(buff[:n]); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+		err = readErr
+	}
+
+	if err == nil && verifier != nil {
+		err = verifier.Verify()
+	}
+	// the bytes which can't be verified are discarded, otherwise everything received is kept
+	if err != nil && verifier != nil {
+		if truncateErr := v.Truncate(offset); truncateErr != nil {
+			return false, errors.Join(err, truncateErr)
+		}
+		return false, err
+	}
+
+	return done, err
+}
+
+func (a *AppBase) CancelUpload(id string, userId string) error {
+	v := NewVideoUploader(a.logger)
+
+	_, err := v.Resume(&VideoUploadData{
+		VideoId: id,
+		UserId:  userId,
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.Cancel()
+}
+
+// CleanupUploadSessions removes the upload sessions which haven't received
+// any data for UploadSessionTTL together with their temporary files and videos.
+func (a *AppBase) CleanupUploadSessions() {
+	records, err := PocketBase.FindAllRecords(entities.UploadSessionsCollection)
+	if err != nil {
+		a.logger.Error("error while finding upload sessions: " + err.Error())
+		return
+	}
+
+	for _, record := range records {
+		session := NewUploadSessionFromRecord(record)
+		if activeUploads.active(session.Video()) {
+			continue
+		}
+
+		stat, err := os.Stat(session.Path())
+		if err == nil && time.Since(stat.ModTime()) < UploadSessionTTL {
+			continue
+		}
+
+		if err = a.removeUploadSession(session); err != nil {
+			a.logger.Error(
+				"error while removing upload session: "+err.Error(),
+				"videoId", session.Video(),
+			)
+		}
+	}
+}
+
+func (a *AppBase) removeUploadSession(session UploadSession) error {
+	if err := os.Remove(session.Path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	video, err := NewVideoFromId(session.Video())
+	if err != nil {
+		return err
+	}
+
+	// the session is removed by the cascade delete of the video
+	return video.Delete()
+}
+
+// UserUsage returns the storage taken by the videos of the user and the quotas of the user.
+func (a *AppBase) UserUsage(id string) (*entities.UserUsage, error) {
+	user, err := NewUserFromId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.Usage()
+}
+
 func (a *AppBase) UpdateVideo(id string, userId string, data *dto.VideoUpdate) error {
 	var err error
 	defer func() {
@@ -229,6 +534,175 @@ func (a *AppBase) UpdateVideo(id string, userId string, data *dto.VideoUpdate) e
 	return video.Save()
 }
 
+// findOwnVideo returns the video if it belongs to the user.
+func (a *AppBase) findOwnVideo(id string, userId string) (Video, error) {
+	video, err := NewVideoFromId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if video.User() != userId {
+		return nil, fmt.Errorf("%w: expected user %s, got %s", ErrVideoAccess, video.User(), userId)
+	}
+
+	return video, nil
+}
+
+func (a *AppBase) PreviewCandidates(id string, userId string) ([]*entities.PreviewCandidate, error) {
+	video, err := a.findOwnVideo(id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return video.PreviewCandidates(), nil
+}
+
+// SetVideoPreview sets the preview chosen by the user from the candidates
+// or from the frame at the given second.
+func (a *AppBase) SetVideoPreview(id string, userId string, data *dto.VideoPreview) error {
+	var err error
+	defer func() {
+		if err != nil {
+			a.logger.Error(
+				"error while setting video preview: "+err.Error(),
+				"videoId", id,
+				"user", userId,
+				"data", data,
+			)
+		}
+	}()
+
+	video, err := a.findOwnVideo(id, userId)
+	if err != nil {
+		return err
+	}
+
+	var file *filesystem.File
+	if data.Candidate != "" {
+		file, err = a.previewFromCandidate(video, data.Candidate)
+	} else if data.Time != nil {
+		file, err = a.previewFromTime(video, *data.Time)
+	} else {
+		err = ErrPreviewCandidate
+	}
+	if err != nil {
+		return err
+	}
+
+	video.SetPreview(file)
+
+	return video.Save()
+}
+
+func (a *AppBase) previewFromCandidate(video Video, candidate string) (*filesystem.File, error) {
+	found := slices.ContainsFunc(video.PreviewCandidates(), func(c *entities.PreviewCandidate) bool {
+		return c.File == candidate
+	})
+	if !found {
+		return nil, ErrPreviewCandidate
+	}
+
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	blob, err := fs.GetReader(video.BaseFilesPath() + "/" + candidate)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	buff, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	return filesystem.NewFileFromBytes(buff, "preview.jpg")
+}
+
+func (a *AppBase) previewFromTime(video Video, second float64) (*filesystem.File, error) {
+	if video.Video() == "" {
+		return nil, ErrVideoNotProcessed
+	}
+	if video.MediaKind() == entities.MediaKindAudio {
+		return nil, ErrPreviewAudio
+	}
+	if second < 0 || second > video.Duration() {
+		return nil, ErrPreviewTime
+	}
+
+	path, cleanup, err := localVideoFile(video)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	ff, err := ffhelp.InputWithRunner(path, FFmpeg)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(UploadDir, "preview_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	frame, err := ff.SaveFrame(filepath.Join(dir, "preview.jpg"), second, DefaultPreviewWidth, DefaultPreviewHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer frame.Close()
+
+	// the file is read on save, after the frame is removed
+	buff, err := io.ReadAll(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	return filesystem.NewFileFromBytes(buff, "preview.jpg")
+}
+
+// localVideoFile returns the path of the stored video file, which can be read by ffmpeg.
+// The file is downloaded to a temporary one, if the storage isn't local.
+func localVideoFile(video Video) (string, func(), error) {
+	key := video.BaseFilesPath() + "/" + video.Video()
+	if !PocketBase.Settings().S3.Enabled {
+		return filepath.Join(PocketBase.DataDir(), core.LocalStorageDirName, key), func() {}, nil
+	}
+
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		return "", nil, err
+	}
+	defer fs.Close()
+
+	blob, err := fs.GetReader(key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer blob.Close()
+
+	if err = os.MkdirAll(UploadDir, 0755); err != nil {
+		return "", nil, err
+	}
+	tmpFile, err := os.CreateTemp(UploadDir, "video_")
+	if err != nil {
+		return "", nil, err
+	}
+	defer tmpFile.Close()
+
+	cleanup := func() { os.Remove(tmpFile.Name()) }
+	if _, err = io.Copy(tmpFile, blob); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return tmpFile.Name(), cleanup, nil
+}
+
 func (a *AppBase) removeVideoFromPlaylists(playlistIds []string, video Video) error {
 	currentPlaylists, err := NewPlaylistsFromVideoId(video.ID())
 	if err != nil {
@@ -248,26 +722,33 @@ func (a *AppBase) removeVideoFromPlaylists(playlistIds []string, video Video) er
 	return nil
 }
 
+// addVideoToPlaylists adds the video to the playlists, which the user can contribute to.
 func (a *AppBase) addVideoToPlaylists(userId string, playlistIds []string, video Video) error {
-	var playlists []Playlist
 	playlists, err := NewPlaylistFromIds(playlistIds)
 	if err != nil {
 		return err
 	}
 
-	err = helper.VerifyFields(
-		playlists,
-		userId,
-		func(playlist Playlist) string {
-			return playlist.User()
-		},
-		func(playlist Playlist) error {
-			playlist.AddVideo(video.ID())
-			return playlist.Save()
-		},
-	)
-	if err != nil {
-		return err
+	for _, playlist := range playlists {
+		role, err := playlist.Role(userId)
+		if err != nil {
+			return err
+		}
+		if !role.Includes(entities.PlaylistRoleContributor) || playlist.Kind() == entities.PlaylistKindSmart {
+			continue
+		}
+
+		// the playlist which already has the video is kept as is
+		err = playlist.InsertVideo(video.ID(), len(playlist.Videos()))
+		if errors.Is(err, ErrPlaylistDuplicate) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = playlist.Save(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -292,7 +773,28 @@ func (a *AppBase) CreatePlaylist(userId string, data *dto.PlaylistCreate) error
 
 	playlist.SetName(data.Name)
 	playlist.SetUser(userId)
-	playlist.SetVideos(data.Videos)
+	// the playlist isn't shown to anyone until it is opened by the user
+	playlist.SetStatus(entities.StatusClosed)
+	if data.Status != "" {
+		playlist.SetStatus(data.Status)
+	}
+
+	switch data.Kind {
+	case "", entities.PlaylistKindManual:
+		playlist.SetKind(entities.PlaylistKindManual)
+		playlist.SetVideos(data.Videos)
+	case entities.PlaylistKindSmart:
+		if err = ValidateSmartQuery(data.Filter, data.Sort); err != nil {
+			return err
+		}
+		playlist.SetKind(entities.PlaylistKindSmart)
+		playlist.SetFilter(data.Filter)
+		playlist.SetSort(data.Sort)
+		playlist.SetLimit(data.Limit)
+	default:
+		err = fmt.Errorf("%w: unknown playlist kind %s", ErrPlaylistFilter, data.Kind)
+		return err
+	}
 
 	return playlist.Save()
 }
@@ -310,37 +812,404 @@ func (a *AppBase) UpdatePlaylist(id string, userId string, data *dto.PlaylistUpd
 		}
 	}()
 
-	playlist, err := NewPlaylistFromId(id)
-	if err != nil {
-		return err
+	// the editors can rename the playlist and change its videos, but only the owner can change its status
+	required := entities.PlaylistRoleEditor
+	if data.Status != "" {
+		required = entities.PlaylistRoleOwner
 	}
 
-	if playlist.User() != userId {
-		err = fmt.Errorf("expected user %s, got %s", playlist.User(), userId)
+	playlist, _, err := findPlaylistWithRole(PocketBase, id, userId, required)
+	if err != nil {
 		return err
 	}
 
 	if data.Name != "" {
 		playlist.SetName(data.Name)
 	}
+	if data.Status != "" {
+		playlist.SetStatus(data.Status)
+	}
+
+	smart := playlist.Kind() == entities.PlaylistKindSmart
 	if data.Videos != nil {
+		if smart {
+			err = ErrPlaylistSmart
+			return err
+		}
 		playlist.SetVideos(data.Videos)
 	}
+	if data.Filter != nil || data.Sort != nil || data.Limit != nil {
+		if !smart {
+			err = fmt.Errorf("%w: manual playlist has no filter", ErrPlaylistFilter)
+			return err
+		}
+		if data.Filter != nil {
+			playlist.SetFilter(*data.Filter)
+		}
+		if data.Sort != nil {
+			playlist.SetSort(*data.Sort)
+		}
+		if data.Limit != nil {
+			playlist.SetLimit(*data.Limit)
+		}
+		if err = ValidateSmartQuery(playlist.Filter(), playlist.Sort()); err != nil {
+			return err
+		}
+	}
 
 	return playlist.Save()
 }
 
+// InsertPlaylistVideo inserts the video to the playlist.
+// The contributors can insert their own videos only.
+func (a *AppBase) InsertPlaylistVideo(id string, userId string, data *dto.PlaylistInsert) (*entities.PlaylistOrder, error) {
+	return a.changePlaylistOrder(
+		id, userId, data.Version, entities.PlaylistRoleContributor,
+		func(txApp core.App, playlist Playlist, role entities.PlaylistRole) error {
+			record, err := txApp.FindRecordById(entities.VideosCollection, data.Video)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrPlaylistVideo, data.Video)
+			}
+			if err = checkOwnVideo(NewVideoFromRecord(record), userId, role); err != nil {
+				return err
+			}
+
+			return playlist.InsertVideo(data.Video, data.Index)
+		},
+	)
+}
+
+// RemovePlaylistVideo removes the video from the playlist.
+// The contributors can remove their own videos only.
+func (a *AppBase) RemovePlaylistVideo(id string, userId string, data *dto.PlaylistRemove) (*entities.PlaylistOrder, error) {
+	return a.changePlaylistOrder(
+		id, userId, data.Version, entities.PlaylistRoleContributor,
+		func(txApp core.App, playlist Playlist, role entities.PlaylistRole) error {
+			if !slices.Contains(playlist.Videos(), data.Video) {
+				return fmt.Errorf("%w: %s", ErrPlaylistVideo, data.Video)
+			}
+
+			// the video can be deleted already, then only the editors can remove it
+			record, err := txApp.FindRecordById(entities.VideosCollection, data.Video)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if !role.Includes(entities.PlaylistRoleEditor) && (record == nil || record.GetString("user") != userId) {
+				return fmt.Errorf("%w: video %s belongs to another user", ErrPlaylistRole, data.Video)
+			}
+
+			playlist.RemoveVideo(data.Video)
+
+			return nil
+		},
+	)
+}
+
+func (a *AppBase) MovePlaylistVideo(id string, userId string, data *dto.PlaylistMove) (*entities.PlaylistOrder, error) {
+	return a.changePlaylistOrder(
+		id, userId, data.Version, entities.PlaylistRoleEditor,
+		func(_ core.App, playlist Playlist, _ entities.PlaylistRole) error {
+			return playlist.MoveVideo(data.From, data.To)
+		},
+	)
+}
+
+func (a *AppBase) ReorderPlaylist(id string, userId string, data *dto.PlaylistReorder) (*entities.PlaylistOrder, error) {
+	return a.changePlaylistOrder(
+		id, userId, data.Version, entities.PlaylistRoleEditor,
+		func(_ core.App, playlist Playlist, _ entities.PlaylistRole) error {
+			return playlist.ReorderVideos(data.Videos)
+		},
+	)
+}
+
+// checkOwnVideo allows the contributors to change the playlist with their own videos only.
+func checkOwnVideo(video Video, userId string, role entities.PlaylistRole) error {
+	if role.Includes(entities.PlaylistRoleEditor) || video.User() == userId {
+		return nil
+	}
+
+	return fmt.Errorf("%w: video %s belongs to another user", ErrPlaylistRole, video.ID())
+}
+
+// changePlaylistOrder changes the videos of the playlist, if the user has the required role
+// and the playlist still has the version the change was made for.
+// The playlist is read and saved in a single transaction, so the concurrent changes can't be lost.
+func (a *AppBase) changePlaylistOrder(
+	id string,
+	userId string,
+	version int,
+	required entities.PlaylistRole,
+	change func(txApp core.App, playlist Playlist, role entities.PlaylistRole) error,
+) (*entities.PlaylistOrder, error) {
+	var (
+		order *entities.PlaylistOrder
+		err   error
+	)
+	defer func() {
+		if err != nil {
+			a.logger.Error(
+				"error while changing playlist order: "+err.Error(),
+				"playlistId", id,
+				"user", userId,
+				"version", version,
+			)
+		}
+	}()
+
+	err = PocketBase.RunInTransaction(func(txApp core.App) error {
+		playlist, role, err := findPlaylistWithRole(txApp, id, userId, required)
+		if err != nil {
+			return err
+		}
+		if playlist.Kind() == entities.PlaylistKindSmart {
+			return ErrPlaylistSmart
+		}
+		if playlist.Version() != version {
+			return fmt.Errorf("%w: expected version %d, got %d", ErrPlaylistVersion, playlist.Version(), version)
+		}
+
+		if err = change(txApp, playlist, role); err != nil {
+			return err
+		}
+		if err = txApp.Save(playlist.ProxyRecord()); err != nil {
+			return err
+		}
+
+		order = &entities.PlaylistOrder{
+			Videos:  playlist.Videos(),
+			Version: playlist.Version(),
+		}
+
+		return nil
+	})
+
+	return order, err
+}
+
+// findPlaylistWithRole returns the playlist and the role of the user in it,
+// if the role includes the required one.
+func findPlaylistWithRole(app core.App, id string, userId string, required entities.PlaylistRole) (Playlist, entities.PlaylistRole, error) {
+	record, err := app.FindRecordById(entities.PlaylistsCollection, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	playlist := NewPlaylistFromRecord(record)
+	role, err := playlist.Role(userId)
+	if err != nil {
+		return nil, "", err
+	}
+	if role == "" {
+		return nil, "", fmt.Errorf("%w: expected user %s, got %s", ErrPlaylistAccess, playlist.User(), userId)
+	}
+	if !role.Includes(required) {
+		return nil, "", fmt.Errorf("%w: expected role %s, got %s", ErrPlaylistRole, required, role)
+	}
+
+	return playlist, role, nil
+}
+
+// InvitePlaylistMember adds the user to the members of the playlist or changes the role of the member.
+// Only the owner can invite the members.
+func (a *AppBase) InvitePlaylistMember(id string, userId string, data *dto.PlaylistInvite) error {
+	var err error
+	defer func() {
+		if err != nil {
+			a.logger.Error(
+				"error while inviting playlist member: "+err.Error(),
+				"playlistId", id,
+				"user", userId,
+				"data", data,
+			)
+		}
+	}()
+
+	if !data.Role.IsMember() {
+		err = fmt.Errorf("%w: %s", ErrPlaylistMemberRole, data.Role)
+		return err
+	}
+
+	err = PocketBase.RunInTransaction(func(txApp core.App) error {
+		playlist, _, err := findPlaylistWithRole(txApp, id, userId, entities.PlaylistRoleOwner)
+		if err != nil {
+			return err
+		}
+		if data.User == playlist.User() {
+			return fmt.Errorf("%w: user %s owns the playlist", ErrPlaylistMemberUser, data.User)
+		}
+		if _, err = txApp.FindRecordById(entities.UsersCollection, data.User); err != nil {
+			return fmt.Errorf("%w: user %s not found", ErrPlaylistMemberUser, data.User)
+		}
+
+		action := entities.PlaylistMemberActionRole
+		member, err := NewPlaylistMemberFromUser(id, data.User)
+		if errors.Is(err, sql.ErrNoRows) {
+			action = entities.PlaylistMemberActionInvite
+			member, err = NewPlaylistMember()
+			if err != nil {
+				return err
+			}
+			member.SetPlaylist(id)
+			member.SetUser(data.User)
+		} else if err != nil {
+			return err
+		} else if member.Role() == data.Role {
+			return nil
+		}
+
+		member.SetRole(data.Role)
+		if err = txApp.Save(member.ProxyRecord()); err != nil {
+			return err
+		}
+
+		return logPlaylistMemberChange(txApp, member, userId, action)
+	})
+
+	return err
+}
+
+// RemovePlaylistMember removes the member from the playlist.
+// The owner can remove any member, the members can leave the playlist themselves.
+func (a *AppBase) RemovePlaylistMember(id string, userId string, memberId string) error {
+	var err error
+	defer func() {
+		if err != nil {
+			a.logger.Error(
+				"error while removing playlist member: "+err.Error(),
+				"playlistId", id,
+				"user", userId,
+				"member", memberId,
+			)
+		}
+	}()
+
+	required := entities.PlaylistRoleOwner
+	if memberId == userId {
+		required = entities.PlaylistRoleViewer
+	}
+
+	err = PocketBase.RunInTransaction(func(txApp core.App) error {
+		if _, _, err := findPlaylistWithRole(txApp, id, userId, required); err != nil {
+			return err
+		}
+
+		member, err := NewPlaylistMemberFromUser(id, memberId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrPlaylistMember, memberId)
+		} else if err != nil {
+			return err
+		}
+
+		if err = txApp.Delete(member.ProxyRecord()); err != nil {
+			return err
+		}
+
+		return logPlaylistMemberChange(txApp, member, userId, entities.PlaylistMemberActionRemove)
+	})
+
+	return err
+}
+
+// ExportPlaylist returns the videos of the playlist, which can be viewed by the requester.
+// The stream URLs of the non-public playlists and videos need the tokens, which are made for the requester
+// and allow to stream their videos only.
+func (a *AppBase) ExportPlaylist(id string, info *core.RequestInfo) (*entities.PlaylistExport, error) {
+	record, err := PocketBase.FindRecordById(entities.PlaylistsCollection, id)
+	if err != nil {
+		return nil, err
+	}
+
+	canAccess, err := PocketBase.CanAccessRecord(record, info, record.Collection().ViewRule)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, fmt.Errorf("%w: %s", ErrPlaylistAccess, id)
+	}
+
+	playlist := NewPlaylistFromRecord(record)
+	ids, err := playlist.ResolveVideos(info)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := PocketBase.FindRecordsByIds(entities.VideosCollection, ids)
+	if err != nil {
+		return nil, err
+	}
+	videos := make(map[string]Video, len(records))
+	for _, record := range records {
+		videos[record.Id] = NewVideoFromRecord(record)
+	}
+
+	export := &entities.PlaylistExport{
+		Id:    playlist.ID(),
+		Name:  playlist.Name(),
+		Items: make([]*entities.PlaylistExportItem, 0, len(ids)),
+	}
+	duration := time.Duration(Config.ExportTokenDuration) * time.Second
+	for _, id := range ids {
+		video, ok := videos[id]
+		if !ok {
+			continue
+		}
+
+		item := &entities.PlaylistExportItem{
+			Id:       video.ID(),
+			Title:    video.Name(),
+			Duration: video.Duration(),
+		}
+		public := playlist.Status() == entities.StatusPublic && video.Status() == entities.StatusPublic
+		if !public && info.Auth != nil {
+			item.Token, err = NewStreamToken(info.Auth, video.ID(), duration)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		export.Items = append(export.Items, item)
+	}
+
+	return export, nil
+}
+
+// logPlaylistMemberChange records the change of the member made by the actor,
+// so the owner can see who changed the members of the playlist.
+func logPlaylistMemberChange(txApp core.App, member PlaylistMember, actorId string, action entities.PlaylistMemberAction) error {
+	col, err := Collections.Get(entities.PlaylistMemberChangesCollection)
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(col)
+	record.Set("playlist", member.Playlist())
+	record.Set("actor", actorId)
+	record.Set("user", member.User())
+	record.Set("action", string(action))
+	if action != entities.PlaylistMemberActionRemove {
+		record.Set("role", string(member.Role()))
+	}
+
+	return txApp.Save(record)
+}
+
 func (a *AppBase) updatePlaylistPreviewFromVideoRecord(playlist Playlist, video Video) error {
 	key := video.BaseFilesPath() + "/" + video.Preview()
 
-	fs, _ := PocketBase.NewFilesystem()
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		return err
+	}
 	defer fs.Close()
 
-	blob, _ := fs.GetReader(key)
+	blob, err := fs.GetReader(key)
+	if err != nil {
+		return err
+	}
 	defer blob.Close()
 
-	buff := make([]byte, blob.Size())
-	_, err := blob.Read(buff)
+	buff, err := io.ReadAll(blob)
 	if err != nil {
 		return err
 	}
@@ -354,21 +1223,67 @@ func (a *AppBase) updatePlaylistPreviewFromVideoRecord(playlist Playlist, video
 	return nil
 }
 
+// updatePlaylistPreview sets the preview of the playlist from its first video, see NewPlaylistPreviewVideo.
 func (a *AppBase) updatePlaylistPreview(e *core.RecordEvent) error {
 	playlist := NewPlaylistFromRecord(e.Record)
 
-	if len(playlist.Videos()) == 0 {
+	video, err := NewPlaylistPreviewVideo(playlist)
+	if err != nil {
+		return err
+	}
+
+	// the video can be still processed, then the preview is made on the next save
+	if video == nil || video.Preview() == "" {
+		playlist.SetPreview(nil)
+		playlist.SetPreviewVideo("")
 		return e.Next()
 	}
 
-	video, err := NewVideoFromId(playlist.Videos()[0])
+	err = a.updatePlaylistPreviewFromVideoRecord(playlist, video)
 	if err != nil {
 		return err
 	}
+	playlist.SetPreviewVideo(video.ID())
 
-	err = a.updatePlaylistPreviewFromVideoRecord(playlist, video)
+	return e.Next()
+}
+
+// refreshSmartPlaylistPreviews makes the previews of the smart playlists again, if the video changes their first video.
+func (a *AppBase) refreshSmartPlaylistPreviews(e *core.RecordEvent) error {
+	playlists, err := NewSmartPlaylistsFromVideo(NewVideoFromRecord(e.Record))
 	if err != nil {
-		return err
+		a.logger.Error(
+			"error while finding smart playlists: "+err.Error(),
+			"videoId", e.Record.Id,
+		)
+		return e.Next()
+	}
+
+	for _, playlist := range playlists {
+		video, err := NewPlaylistPreviewVideo(playlist)
+		if err != nil {
+			a.logger.Error(
+				"error while resolving smart playlist: "+err.Error(),
+				"playlistId", playlist.ID(),
+			)
+			continue
+		}
+
+		previewVideo := ""
+		if video != nil && video.Preview() != "" {
+			previewVideo = video.ID()
+		}
+		if previewVideo == playlist.PreviewVideo() {
+			continue
+		}
+
+		// the preview is made by the playlist save hooks
+		if err = playlist.Save(); err != nil {
+			a.logger.Error(
+				"error while refreshing smart playlist: "+err.Error(),
+				"playlistId", playlist.ID(),
+			)
+		}
 	}
 
 	return e.Next()
@@ -388,13 +1303,22 @@ func (a *AppBase) updatePlaylistPreviewFromVideo(e *core.RecordEvent) error {
 	return e.Next()
 }
 
+// enrichVideo adds the ids of the playlists with the video, which are listed to the requester.
 func (a *AppBase) enrichVideo(e *core.RecordEnrichEvent) error {
-	playlists, err := NewPlaylistsFromVideoId(e.Record.Id)
+	var (
+		playlists []Playlist
+		err       error
+	)
+	if e.RequestInfo.HasSuperuserAuth() {
+		playlists, err = NewPlaylistsFromVideoId(e.Record.Id)
+	} else {
+		playlists, err = NewVisiblePlaylistsFromVideoId(e.Record.Id, requesterId(e.RequestInfo))
+	}
 	if err != nil {
 		return err
 	}
 
-	playlistIds := make([]string, len(playlists))
+	playlistIds := make([]string, 0, len(playlists))
 	for _, playlist := range playlists {
 		playlistIds = append(playlistIds, playlist.ID())
 	}
@@ -404,3 +1328,25 @@ func (a *AppBase) enrichVideo(e *core.RecordEnrichEvent) error {
 
 	return e.Next()
 }
+
+// enrichPlaylist sets the videos of the playlist, which can be viewed by the requester.
+// The videos of the smart playlists are found on every read.
+func (a *AppBase) enrichPlaylist(e *core.RecordEnrichEvent) error {
+	playlist := NewPlaylistFromRecord(e.Record)
+	videos, err := playlist.ResolveVideos(e.RequestInfo)
+	if err != nil {
+		return err
+	}
+
+	e.Record.Set("videos", videos)
+
+	return e.Next()
+}
+
+func requesterId(info *core.RequestInfo) string {
+	if info == nil || info.Auth == nil {
+		return ""
+	}
+
+	return info.Auth.Id
+}
@@ -0,0 +1,109 @@
+package vhs
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"vhs/pkg/ffhelp"
+)
+
+// AppConfig is the configuration of the uploads and their processing.
+// The defaults can be changed with the VHS_* environment variables, see LoadConfig.
+type AppConfig struct {
+	// Allowlist is the containers and codecs which are accepted for upload.
+	// The file type must also be allowed by the "video" field of the videos collection.
+	Allowlist ffhelp.Allowlist
+	// ProbeHeadSize is the number of received bytes after which the upload is probed for the first time.
+	ProbeHeadSize int
+	// QuotaBytes and QuotaVideos are the quotas of the users, which have none of their own.
+	// Zero means no quota.
+	QuotaBytes  int
+	QuotaVideos int
+	// MaxUploadsPerUser is the number of files a user can write at once, zero means no limit.
+	MaxUploadsPerUser int
+	// ProcessingWorkers is the number of videos processed at once, the rest wait in the queue.
+	ProcessingWorkers int
+	// MaxFFmpegProcesses is the number of ffmpeg and ffprobe processes run at once by all the workers and uploads.
+	MaxFFmpegProcesses int
+	// ExportTokenDuration is the number of seconds the stream URLs of the exported playlists can be played for.
+	ExportTokenDuration int
+}
+
+var Config = DefaultConfig()
+
+func DefaultConfig() *AppConfig {
+	return &AppConfig{
+		Allowlist: ffhelp.Allowlist{
+			Containers:  []string{"mp4", "m4a", "webm", "matroska", "mp3", "flac", "ogg", "wav", "aac"},
+			VideoCodecs: []string{"h264", "hevc", "av1", "vp9", "mpeg4"},
+			AudioCodecs: []string{"aac", "mp3", "opus", "vorbis", "flac", "alac", "ac3", "eac3", "pcm_s16le", "pcm_s24le"},
+		},
+		ProbeHeadSize:       4 << 20,
+		MaxUploadsPerUser:   3,
+		ProcessingWorkers:   2,
+		MaxFFmpegProcesses:  4,
+		ExportTokenDuration: 4 * 60 * 60,
+	}
+}
+
+// LoadConfig returns the default config changed by the environment variables:
+//
+//	VHS_ALLOWED_CONTAINERS    comma separated ffprobe format names
+//	VHS_ALLOWED_VIDEO_CODECS  comma separated ffprobe codec names
+//	VHS_ALLOWED_AUDIO_CODECS  comma separated ffprobe codec names
+//	VHS_PROBE_HEAD_SIZE       bytes
+//	VHS_QUOTA_BYTES           bytes
+//	VHS_QUOTA_VIDEOS          number of videos
+//	VHS_MAX_UPLOADS_PER_USER  number of uploads
+//	VHS_PROCESSING_WORKERS    number of workers, at least 1
+//	VHS_MAX_FFMPEG_PROCESSES  number of processes, at least 1
+//	VHS_EXPORT_TOKEN_DURATION seconds, at least 60
+func LoadConfig(logger *slog.Logger) *AppConfig {
+	config := DefaultConfig()
+
+	envList("VHS_ALLOWED_CONTAINERS", &config.Allowlist.Containers)
+	envList("VHS_ALLOWED_VIDEO_CODECS", &config.Allowlist.VideoCodecs)
+	envList("VHS_ALLOWED_AUDIO_CODECS", &config.Allowlist.AudioCodecs)
+	envInt(logger, "VHS_PROBE_HEAD_SIZE", &config.ProbeHeadSize, 0)
+	envInt(logger, "VHS_QUOTA_BYTES", &config.QuotaBytes, 0)
+	envInt(logger, "VHS_QUOTA_VIDEOS", &config.QuotaVideos, 0)
+	envInt(logger, "VHS_MAX_UPLOADS_PER_USER", &config.MaxUploadsPerUser, 0)
+	envInt(logger, "VHS_PROCESSING_WORKERS", &config.ProcessingWorkers, 1)
+	envInt(logger, "VHS_MAX_FFMPEG_PROCESSES", &config.MaxFFmpegProcesses, 1)
+	envInt(logger, "VHS_EXPORT_TOKEN_DURATION", &config.ExportTokenDuration, 60)
+
+	return config
+}
+
+func envList(name string, value *[]string) {
+	env, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+
+	list := make([]string, 0)
+	for _, item := range strings.Split(env, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	*value = list
+}
+
+// envInt keeps the default value if the variable isn't an integer of at least the minValue.
+func envInt(logger *slog.Logger, name string, value *int, minValue int) {
+	env, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(env))
+	if err != nil || n < minValue {
+		logger.Warn("invalid config value, the default is used", "name", name, "value", env, "default", *value)
+		return
+	}
+
+	*value = n
+}
@@ -1,6 +1,12 @@
 package entities
 
 const (
-	VideosCollection    = "videos"
-	PlaylistsCollection = "playlists"
+	VideosCollection         = "videos"
+	PlaylistsCollection      = "playlists"
+	UploadSessionsCollection = "upload_sessions"
+	JobsCollection           = "jobs"
+	UsersCollection          = "users"
+
+	PlaylistMembersCollection       = "playlist_members"
+	PlaylistMemberChangesCollection = "playlist_member_changes"
 )
@@ -1,35 +1,165 @@
 package dto
 
+import "vhs/internal/vhs/entities"
+
 type PlaylistCreateRequest struct {
 	Name   string   `form:"name" json:"name"`
+	Status string   `form:"status" json:"status"`
 	Videos []string `form:"videos" json:"videos"`
+	Kind   string   `form:"kind" json:"kind"`
+	Filter string   `form:"filter" json:"filter"`
+	Sort   string   `form:"sort" json:"sort"`
+	Limit  int      `form:"limit" json:"limit"`
 }
 
+// PlaylistCreate creates the manual playlist of the Videos
+// or the smart playlist of the videos found by the Filter, Sort and Limit.
 type PlaylistCreate struct {
 	Name   string
+	Status entities.Status
 	Videos []string
+	Kind   entities.PlaylistKind
+	Filter string
+	Sort   string
+	Limit  int
 }
 
 func NewPlaylistCreate(req *PlaylistCreateRequest) *PlaylistCreate {
 	return &PlaylistCreate{
 		Name:   req.Name,
+		Status: entities.Status(req.Status),
 		Videos: req.Videos,
+		Kind:   entities.PlaylistKind(req.Kind),
+		Filter: req.Filter,
+		Sort:   req.Sort,
+		Limit:  req.Limit,
 	}
 }
 
 type PlaylistUpdateRequest struct {
 	Name   string   `form:"name" json:"name"`
+	Status string   `form:"status" json:"status"`
 	Videos []string `form:"videos" json:"videos"`
+	Filter *string  `form:"filter" json:"filter"`
+	Sort   *string  `form:"sort" json:"sort"`
+	Limit  *int     `form:"limit" json:"limit"`
 }
 
+// PlaylistUpdate changes the fields of the playlist, which are set.
+// The Filter, Sort and Limit can be changed for the smart playlists only.
 type PlaylistUpdate struct {
 	Name   string
+	Status entities.Status
 	Videos []string
+	Filter *string
+	Sort   *string
+	Limit  *int
 }
 
 func NewPlaylistUpdate(req *PlaylistUpdateRequest) *PlaylistUpdate {
 	return &PlaylistUpdate{
 		Name:   req.Name,
+		Status: entities.Status(req.Status),
 		Videos: req.Videos,
+		Filter: req.Filter,
+		Sort:   req.Sort,
+		Limit:  req.Limit,
+	}
+}
+
+type PlaylistInsertRequest struct {
+	Video   string `form:"video" json:"video"`
+	Index   int    `form:"index" json:"index"`
+	Version *int   `form:"version" json:"version"`
+}
+
+// PlaylistInsert inserts the Video at the Index of the playlist of the Version.
+type PlaylistInsert struct {
+	Video   string
+	Index   int
+	Version int
+}
+
+func NewPlaylistInsert(req *PlaylistInsertRequest) *PlaylistInsert {
+	return &PlaylistInsert{
+		Video:   req.Video,
+		Index:   req.Index,
+		Version: *req.Version,
+	}
+}
+
+type PlaylistMoveRequest struct {
+	From    int  `form:"from" json:"from"`
+	To      int  `form:"to" json:"to"`
+	Version *int `form:"version" json:"version"`
+}
+
+// PlaylistMove moves the video From one index To another in the playlist of the Version.
+type PlaylistMove struct {
+	From    int
+	To      int
+	Version int
+}
+
+func NewPlaylistMove(req *PlaylistMoveRequest) *PlaylistMove {
+	return &PlaylistMove{
+		From:    req.From,
+		To:      req.To,
+		Version: *req.Version,
+	}
+}
+
+type PlaylistReorderRequest struct {
+	Videos  []string `form:"videos" json:"videos"`
+	Version *int     `form:"version" json:"version"`
+}
+
+// PlaylistReorder sets the new order of all the Videos of the playlist of the Version.
+type PlaylistReorder struct {
+	Videos  []string
+	Version int
+}
+
+func NewPlaylistReorder(req *PlaylistReorderRequest) *PlaylistReorder {
+	return &PlaylistReorder{
+		Videos:  req.Videos,
+		Version: *req.Version,
+	}
+}
+
+type PlaylistRemoveRequest struct {
+	Video   string `form:"video" json:"video"`
+	Version *int   `form:"version" json:"version"`
+}
+
+// PlaylistRemove removes the Video from the playlist of the Version.
+type PlaylistRemove struct {
+	Video   string
+	Version int
+}
+
+func NewPlaylistRemove(req *PlaylistRemoveRequest) *PlaylistRemove {
+	return &PlaylistRemove{
+		Video:   req.Video,
+		Version: *req.Version,
+	}
+}
+
+type PlaylistInviteRequest struct {
+	User string `form:"user" json:"user"`
+	Role string `form:"role" json:"role"`
+}
+
+// PlaylistInvite adds the User to the members of the playlist with the Role,
+// or changes the Role of the member.
+type PlaylistInvite struct {
+	User string
+	Role entities.PlaylistRole
+}
+
+func NewPlaylistInvite(req *PlaylistInviteRequest) *PlaylistInvite {
+	return &PlaylistInvite{
+		User: req.User,
+		Role: entities.PlaylistRole(req.Role),
 	}
 }
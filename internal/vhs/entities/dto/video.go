@@ -31,3 +31,21 @@ func NewVideoUpdate(req *VideoUpdateRequest) *VideoUpdate {
 		PlaylistIds: req.PlaylistIds,
 	}
 }
+
+type VideoPreviewRequest struct {
+	Time      *float64 `form:"time" json:"time"`
+	Candidate string   `form:"candidate" json:"candidate"`
+}
+
+// VideoPreview sets the preview either from the Candidate file or from the frame at the Time.
+type VideoPreview struct {
+	Time      *float64
+	Candidate string
+}
+
+func NewVideoPreview(req *VideoPreviewRequest) *VideoPreview {
+	return &VideoPreview{
+		Time:      req.Time,
+		Candidate: req.Candidate,
+	}
+}
@@ -0,0 +1,10 @@
+package entities
+
+type JobState string
+
+const (
+	JobStateQueued    JobState = "queued"
+	JobStateRunning   JobState = "running"
+	JobStateFailed    JobState = "failed"
+	JobStateSucceeded JobState = "succeeded"
+)
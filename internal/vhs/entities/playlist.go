@@ -0,0 +1,77 @@
+package entities
+
+import "slices"
+
+// PlaylistOrder is the order of the videos of the playlist after a change,
+// the Version is sent with the next change.
+type PlaylistOrder struct {
+	Videos  []string `json:"videos"`
+	Version int      `json:"version"`
+}
+
+// PlaylistRole is the role of a member of the playlist,
+// every role can do all the things the roles before it can.
+type PlaylistRole string
+
+const (
+	// PlaylistRoleViewer can view the playlist regardless of its status.
+	PlaylistRoleViewer PlaylistRole = "viewer"
+	// PlaylistRoleContributor can add and remove their own videos.
+	PlaylistRoleContributor PlaylistRole = "contributor"
+	// PlaylistRoleEditor can add, remove and reorder any videos and rename the playlist.
+	PlaylistRoleEditor PlaylistRole = "editor"
+	// PlaylistRoleOwner is the role of the user the playlist belongs to, it isn't given to the members.
+	PlaylistRoleOwner PlaylistRole = "owner"
+)
+
+var playlistRoles = []PlaylistRole{
+	PlaylistRoleViewer,
+	PlaylistRoleContributor,
+	PlaylistRoleEditor,
+	PlaylistRoleOwner,
+}
+
+// IsMember reports if the role can be given to a member of the playlist.
+func (r PlaylistRole) IsMember() bool {
+	return r == PlaylistRoleViewer || r == PlaylistRoleContributor || r == PlaylistRoleEditor
+}
+
+// Includes reports if the role can do everything the other role can.
+// No role includes nothing.
+func (r PlaylistRole) Includes(other PlaylistRole) bool {
+	return r != "" && slices.Index(playlistRoles, r) >= slices.Index(playlistRoles, other)
+}
+
+type PlaylistMemberAction string
+
+const (
+	PlaylistMemberActionInvite PlaylistMemberAction = "invite"
+	PlaylistMemberActionRole   PlaylistMemberAction = "role"
+	PlaylistMemberActionRemove PlaylistMemberAction = "remove"
+)
+
+type PlaylistKind string
+
+const (
+	// PlaylistKindManual is the playlist of the videos added by the members.
+	PlaylistKindManual PlaylistKind = "manual"
+	// PlaylistKindSmart is the playlist of the videos found by its filter, sort and limit.
+	PlaylistKindSmart PlaylistKind = "smart"
+)
+
+// PlaylistExport is the playlist with the videos, which can be viewed by the requester, in their order.
+type PlaylistExport struct {
+	Id    string                `json:"id"`
+	Name  string                `json:"name"`
+	Items []*PlaylistExportItem `json:"items"`
+}
+
+// PlaylistExportItem is a video of the exported playlist.
+// The Token is the access token of its stream URL, it is empty if the video can be played without it.
+type PlaylistExportItem struct {
+	Id       string  `json:"id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	URL      string  `json:"url"`
+	Token    string  `json:"-"`
+}
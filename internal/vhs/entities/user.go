@@ -0,0 +1,10 @@
+package entities
+
+// UserUsage is the storage taken by the videos of the user and the quotas of the user.
+// Zero quota means no quota.
+type UserUsage struct {
+	Bytes       int `json:"bytes" db:"bytes"`
+	Videos      int `json:"videos" db:"videos"`
+	QuotaBytes  int `json:"quotaBytes" db:"-"`
+	QuotaVideos int `json:"quotaVideos" db:"-"`
+}
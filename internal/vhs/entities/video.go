@@ -15,10 +15,45 @@ const (
 	StatusClosed        = "closed"
 )
 
+// MediaKind is what was uploaded: a video, or an audio without a video stream.
+type MediaKind string
+
+const (
+	MediaKindVideo MediaKind = "video"
+	MediaKindAudio MediaKind = "audio"
+)
+
+type ProcessingState string
+
+const (
+	ProcessingStateUploading   ProcessingState = "uploading"
+	ProcessingStateQueued      ProcessingState = "queued"
+	ProcessingStateProbing     ProcessingState = "probing"
+	ProcessingStateThumbnails  ProcessingState = "thumbnails"
+	ProcessingStateSheets      ProcessingState = "sheets"
+	ProcessingStateWebVTT      ProcessingState = "webvtt"
+	ProcessingStatePreview     ProcessingState = "preview"
+	ProcessingStateTeaser      ProcessingState = "teaser"
+	ProcessingStateWaveform    ProcessingState = "waveform"
+	ProcessingStateTranscoding ProcessingState = "transcoding"
+	ProcessingStateReady       ProcessingState = "ready"
+	ProcessingStateFailed      ProcessingState = "failed"
+)
+
 type VideoInfo struct {
-	Meta     *ffhelp.Probe   `json:"meta"`
-	Duration float64         `json:"duration"`
-	Chapters []*VideoChapter `json:"chapters"`
+	Meta       *ffhelp.Probe      `json:"meta"`
+	Duration   float64            `json:"duration"`
+	Chapters   []*VideoChapter    `json:"chapters"`
+	Renditions []ffhelp.Rendition `json:"renditions"`
+	// PreviewCandidates are in the order of the "preview_candidates" files.
+	PreviewCandidates []*PreviewCandidate `json:"previewCandidates"`
+}
+
+// PreviewCandidate is a frame of the video, which can be chosen as its preview.
+type PreviewCandidate struct {
+	Time  float64 `json:"time"`
+	Score float64 `json:"score"`
+	File  string  `json:"file,omitempty"`
 }
 
 type VideoChapter struct {
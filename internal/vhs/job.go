@@ -0,0 +1,23 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type Job interface {
+	core.RecordProxy
+	Save() error
+	ID() string
+	Video() string
+	SetVideo(string)
+	File() string
+	SetFile(string)
+	State() entities.JobState
+	SetState(entities.JobState)
+	Attempts() int
+	SetAttempts(int)
+	LastError() string
+	SetLastError(string)
+}
@@ -0,0 +1,95 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type JobBase struct {
+	core.BaseRecordProxy
+}
+
+func NewJob() (Job, error) {
+	col, err := Collections.Get(entities.JobsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJobFromRecord(core.NewRecord(col)), nil
+}
+
+func NewJobFromRecord(record *core.Record) Job {
+	j := &JobBase{}
+	j.SetProxyRecord(record)
+
+	return j
+}
+
+func NewJobsFromState(state entities.JobState, limit int) ([]Job, error) {
+	records, err := PocketBase.FindRecordsByFilter(
+		entities.JobsCollection,
+		"state = {:state}",
+		"created",
+		limit,
+		0,
+		map[string]any{"state": string(state)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, len(records))
+	for i, record := range records {
+		jobs[i] = NewJobFromRecord(record)
+	}
+	return jobs, nil
+}
+
+func (j *JobBase) Save() error {
+	return PocketBase.Save(j)
+}
+
+func (j *JobBase) ID() string {
+	return j.Id
+}
+
+func (j *JobBase) Video() string {
+	return j.GetString("video")
+}
+
+func (j *JobBase) SetVideo(id string) {
+	j.Set("video", id)
+}
+
+func (j *JobBase) File() string {
+	return j.GetString("file")
+}
+
+func (j *JobBase) SetFile(path string) {
+	j.Set("file", path)
+}
+
+func (j *JobBase) State() entities.JobState {
+	return entities.JobState(j.GetString("state"))
+}
+
+func (j *JobBase) SetState(state entities.JobState) {
+	j.Set("state", string(state))
+}
+
+func (j *JobBase) Attempts() int {
+	return j.GetInt("attempts")
+}
+
+func (j *JobBase) SetAttempts(attempts int) {
+	j.Set("attempts", attempts)
+}
+
+func (j *JobBase) LastError() string {
+	return j.GetString("error")
+}
+
+func (j *JobBase) SetLastError(s string) {
+	j.Set("error", s)
+}
@@ -0,0 +1,6 @@
+package vhs
+
+type JobQueue interface {
+	Start() error
+	Enqueue(videoId string, file string) error
+}
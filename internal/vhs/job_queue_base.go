@@ -0,0 +1,227 @@
+package vhs
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"vhs/internal/vhs/entities"
+)
+
+const (
+	JobMaxAttempts  = 3
+	JobPollInterval = 30 * time.Second
+)
+
+// JobQueueBase processes the uploaded videos by a bounded pool of workers.
+// The jobs are stored in the jobs collection, so they survive restarts.
+type JobQueueBase struct {
+	logger  *slog.Logger
+	workers int
+	wake    chan struct{}
+	// claimMu prevents the workers from taking the same job
+	claimMu sync.Mutex
+}
+
+func NewJobQueue(logger *slog.Logger, workers int) JobQueue {
+	return &JobQueueBase{
+		logger:  logger,
+		workers: workers,
+		wake:    make(chan struct{}, workers),
+	}
+}
+
+// Start requeues the jobs interrupted by the previous shutdown and starts the workers.
+func (q *JobQueueBase) Start() error {
+	jobs, err := NewJobsFromState(entities.JobStateRunning, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		q.logger.Warn("requeue interrupted job", "job", job.ID(), "video", job.Video())
+
+		if err = q.complete(job, errors.New("processing was interrupted")); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < q.workers; i++ {
+		go q.work()
+	}
+
+	return nil
+}
+
+func (q *JobQueueBase) Enqueue(videoId string, file string) error {
+	job, err := NewJob()
+	if err != nil {
+		return err
+	}
+
+	job.SetVideo(videoId)
+	job.SetFile(file)
+	job.SetState(entities.JobStateQueued)
+
+	if err = job.Save(); err != nil {
+		return err
+	}
+
+	q.notify()
+
+	return nil
+}
+
+func (q *JobQueueBase) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueueBase) work() {
+	for {
+		ok, err := q.RunNext()
+		if err != nil {
+			q.logger.Error("error while claiming job: " + err.Error())
+		}
+
+		if !ok {
+			select {
+			case <-q.wake:
+			case <-time.After(JobPollInterval):
+			}
+		}
+	}
+}
+
+// RunNext processes the oldest queued job, it returns false if there is none.
+func (q *JobQueueBase) RunNext() (bool, error) {
+	job, err := q.claim()
+	if job == nil {
+		return false, err
+	}
+
+	q.run(job)
+
+	return true, nil
+}
+
+// claim marks the oldest queued job as running.
+func (q *JobQueueBase) claim() (Job, error) {
+	q.claimMu.Lock()
+	defer q.claimMu.Unlock()
+
+	jobs, err := NewJobsFromState(entities.JobStateQueued, 1)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	job := jobs[0]
+	job.SetState(entities.JobStateRunning)
+	job.SetAttempts(job.Attempts() + 1)
+
+	if err = job.Save(); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (q *JobQueueBase) run(job Job) {
+	err := q.process(job)
+	if err != nil {
+		q.logger.Error(
+			"error while video processing: "+err.Error(),
+			"job", job.ID(),
+			"video", job.Video(),
+			"attempt", job.Attempts(),
+		)
+	}
+
+	if err = q.complete(job, err); err != nil {
+		q.logger.Error(
+			"error while saving job: "+err.Error(),
+			"job", job.ID(),
+		)
+	}
+}
+
+func (q *JobQueueBase) process(job Job) error {
+	video, err := NewVideoFromId(job.Video())
+	if err != nil {
+		return err
+	}
+
+	processor, err := NewVideoProcessor(video, job.File(), q.logger)
+	if err != nil {
+		return err
+	}
+	defer processor.tmpFile.Close()
+
+	return processor.Process()
+}
+
+// complete saves the result of the job. The failed job is requeued until it is out of attempts.
+// The uploaded file is kept until the job is finished.
+func (q *JobQueueBase) complete(job Job, cause error) error {
+	switch {
+	case cause == nil:
+		job.SetState(entities.JobStateSucceeded)
+		job.SetLastError("")
+	case job.Attempts() >= JobMaxAttempts:
+		job.SetState(entities.JobStateFailed)
+		job.SetLastError(cause.Error())
+	default:
+		job.SetState(entities.JobStateQueued)
+		job.SetLastError(cause.Error())
+		defer q.notify()
+	}
+
+	if job.State() != entities.JobStateQueued {
+		q.removeFile(job)
+	}
+	if cause != nil {
+		q.setVideoError(job, cause)
+	}
+
+	return job.Save()
+}
+
+// setVideoError reports the failed attempt on the video.
+func (q *JobQueueBase) setVideoError(job Job, cause error) {
+	video, err := NewVideoFromId(job.Video())
+	if err != nil {
+		q.logger.Error(
+			"error while finding job video: "+err.Error(),
+			"job", job.ID(),
+		)
+		return
+	}
+
+	state := entities.ProcessingStateQueued
+	if job.State() == entities.JobStateFailed {
+		state = entities.ProcessingStateFailed
+	}
+
+	video.SetProcessingState(state, 0)
+	video.SetProcessingError(processingErrorMessage(cause))
+
+	if err = video.Save(); err != nil {
+		q.logger.Error(
+			"error while saving video processing error: "+err.Error(),
+			"job", job.ID(),
+		)
+	}
+}
+
+func (q *JobQueueBase) removeFile(job Job) {
+	err := os.Remove(job.File())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		q.logger.Error(
+			"error while removing job file: "+err.Error(),
+			"job", job.ID(),
+		)
+	}
+}
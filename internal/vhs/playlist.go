@@ -1,6 +1,9 @@
 package vhs
 
 import (
+	"errors"
+	"vhs/internal/vhs/entities"
+
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
 )
@@ -14,11 +17,49 @@ type Playlist interface {
 	SetName(string)
 	User() string
 	SetUser(string)
+	Status() entities.Status
+	SetStatus(entities.Status)
+	Role(userId string) (entities.PlaylistRole, error)
+	Kind() entities.PlaylistKind
+	SetKind(entities.PlaylistKind)
+	Filter() string
+	SetFilter(string)
+	Sort() string
+	SetSort(string)
+	Limit() int
+	SetLimit(int)
 	Videos() []string
+	ResolveVideos(info *core.RequestInfo) ([]string, error)
+	OwnerVideos() ([]string, error)
 	SetVideos([]string)
 	AddVideo(string)
 	AddVideos([]string)
 	RemoveVideo(string)
+	InsertVideo(id string, index int) error
+	MoveVideo(from int, to int) error
+	ReorderVideos([]string) error
+	Version() int
 	Preview() string
 	SetPreview(*filesystem.File)
+	PreviewVideo() string
+	SetPreviewVideo(string)
 }
+
+// SmartPlaylistMaxLimit is the max number of the videos of a smart playlist,
+// it is also used when the playlist has no limit.
+const SmartPlaylistMaxLimit = 100
+
+var (
+	ErrPlaylistAccess     = errors.New("playlist belongs to another user")
+	ErrPlaylistRole       = errors.New("playlist role doesn't allow the change")
+	ErrPlaylistVersion    = errors.New("playlist was changed by another request")
+	ErrPlaylistIndex      = errors.New("playlist index is out of range")
+	ErrPlaylistDuplicate  = errors.New("video is already in the playlist")
+	ErrPlaylistReorder    = errors.New("reordered videos don't match the videos of the playlist")
+	ErrPlaylistVideo      = errors.New("video not found")
+	ErrPlaylistMember     = errors.New("user isn't a member of the playlist")
+	ErrPlaylistMemberUser = errors.New("user can't be a member of the playlist")
+	ErrPlaylistMemberRole = errors.New("unknown playlist member role")
+	ErrPlaylistSmart      = errors.New("videos of the smart playlist are found by its filter")
+	ErrPlaylistFilter     = errors.New("invalid smart playlist filter")
+)
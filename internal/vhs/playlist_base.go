@@ -1,11 +1,17 @@
 package vhs
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 	"vhs/internal/vhs/entities"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/search"
+	"github.com/pocketbase/pocketbase/tools/types"
 	"golang.org/x/exp/slices"
 )
 
@@ -52,13 +58,38 @@ func NewPlaylistFromIds(ids []string) ([]Playlist, error) {
 }
 
 func NewPlaylistsFromVideoId(videoId string) ([]Playlist, error) {
-	records, err := PocketBase.FindRecordsByFilter(
-		entities.PlaylistsCollection,
-		fmt.Sprintf("videos.id ?= '%s'", videoId),
-		"",
-		0,
-		0,
+	return newPlaylistsFromFilter("videos.id ?= {:videoId}", dbx.Params{"videoId": videoId})
+}
+
+// NewVisiblePlaylistsFromVideoId returns the playlists with the video, which are listed to the user:
+// the playlists of the user, the playlists the user is a member of and the public ones.
+func NewVisiblePlaylistsFromVideoId(videoId string, userId string) ([]Playlist, error) {
+	params := dbx.Params{"videoId": videoId, "userId": userId, "status": string(entities.StatusPublic)}
+	// the empty user id matches the playlists without members
+	if userId == "" {
+		return newPlaylistsFromFilter("videos.id ?= {:videoId} && status = {:status}", params)
+	}
+
+	return newPlaylistsFromFilter(
+		"videos.id ?= {:videoId} && (user = {:userId} || status = {:status} || playlist_members_via_playlist.user ?= {:userId})",
+		params,
 	)
+}
+
+// NewSmartPlaylistsFromVideo returns the smart playlists, which preview can be changed by the video.
+// The preview is taken from the public videos only, see NewPlaylistPreviewVideo,
+// so the other videos can only stop being the preview.
+func NewSmartPlaylistsFromVideo(video Video) ([]Playlist, error) {
+	params := dbx.Params{"kind": string(entities.PlaylistKindSmart), "videoId": video.ID()}
+	if video.Status() == entities.StatusPublic {
+		return newPlaylistsFromFilter("kind = {:kind}", params)
+	}
+
+	return newPlaylistsFromFilter("kind = {:kind} && preview_video = {:videoId}", params)
+}
+
+func newPlaylistsFromFilter(filter string, params dbx.Params) ([]Playlist, error) {
+	records, err := PocketBase.FindRecordsByFilter(entities.PlaylistsCollection, filter, "", 0, 0, params)
 	if err != nil {
 		return nil, err
 	}
@@ -98,20 +129,122 @@ func (p *PlaylistBase) SetUser(id string) {
 	p.Set("user", id)
 }
 
+func (p *PlaylistBase) Status() entities.Status {
+	return entities.Status(p.GetString("status"))
+}
+
+func (p *PlaylistBase) SetStatus(status entities.Status) {
+	p.Set("status", string(status))
+}
+
+// Role returns the role of the user in the playlist.
+// The owner has the PlaylistRoleOwner, the users who aren't members have no role.
+func (p *PlaylistBase) Role(userId string) (entities.PlaylistRole, error) {
+	if userId == "" {
+		return "", nil
+	}
+	if p.User() == userId {
+		return entities.PlaylistRoleOwner, nil
+	}
+
+	member, err := NewPlaylistMemberFromUser(p.Id, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return member.Role(), nil
+}
+
+// Kind returns the kind of the playlist, the playlists without a kind are manual.
+func (p *PlaylistBase) Kind() entities.PlaylistKind {
+	if kind := p.GetString("kind"); kind != "" {
+		return entities.PlaylistKind(kind)
+	}
+
+	return entities.PlaylistKindManual
+}
+
+func (p *PlaylistBase) SetKind(kind entities.PlaylistKind) {
+	p.Set("kind", string(kind))
+}
+
+// Filter is the PocketBase filter of the videos of the smart playlist.
+func (p *PlaylistBase) Filter() string {
+	return p.GetString("filter")
+}
+
+func (p *PlaylistBase) SetFilter(filter string) {
+	p.Set("filter", filter)
+}
+
+// Sort is the PocketBase sort of the videos of the smart playlist, e.g. "-created".
+func (p *PlaylistBase) Sort() string {
+	return p.GetString("sort")
+}
+
+func (p *PlaylistBase) SetSort(sort string) {
+	p.Set("sort", sort)
+}
+
+// Limit is the max number of the videos of the smart playlist, up to the SmartPlaylistMaxLimit.
+func (p *PlaylistBase) Limit() int {
+	if limit := p.GetInt("limit"); limit > 0 && limit < SmartPlaylistMaxLimit {
+		return limit
+	}
+
+	return SmartPlaylistMaxLimit
+}
+
+func (p *PlaylistBase) SetLimit(limit int) {
+	p.Set("limit", limit)
+}
+
+// Videos returns the stored videos in the order of the manual playlist.
+// The smart playlist has no stored videos, see ResolveVideos.
 func (p *PlaylistBase) Videos() []string {
 	return p.GetStringSlice("videos")
 }
 
+// OwnerVideos returns the videos of the playlist, which can be viewed by its owner.
+// The videos of the smart playlist are found with the access of the owner,
+// the same way the owner chooses the videos of the manual playlist.
+func (p *PlaylistBase) OwnerVideos() ([]string, error) {
+	if p.Kind() != entities.PlaylistKindSmart {
+		return p.Videos(), nil
+	}
+
+	owner, err := PocketBase.FindRecordById(entities.UsersCollection, p.User())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.ResolveVideos(&core.RequestInfo{Context: core.RequestInfoContextDefault, Auth: owner})
+}
+
+// ResolveVideos returns the videos of the playlist, which can be viewed by the requester.
+func (p *PlaylistBase) ResolveVideos(info *core.RequestInfo) ([]string, error) {
+	if p.Kind() == entities.PlaylistKindSmart {
+		return findSmartVideos(p.Filter(), p.Sort(), p.Limit(), info)
+	}
+
+	return viewableVideos(p.Videos(), info)
+}
+
+// SetVideos sets the videos in the order of the playlist.
+// Every change of the videos increments the version of the playlist.
 func (p *PlaylistBase) SetVideos(ids []string) {
 	p.Set("videos", ids)
+	p.Set("version", p.Version()+1)
 }
 
 func (p *PlaylistBase) AddVideo(id string) {
-	p.Set("videos", append(p.Videos(), id))
+	p.SetVideos(append(p.Videos(), id))
 }
 
 func (p *PlaylistBase) AddVideos(ids []string) {
-	p.Set("videos", append(p.Videos(), ids...))
+	p.SetVideos(append(p.Videos(), ids...))
 }
 
 func (p *PlaylistBase) RemoveVideo(id string) {
@@ -122,10 +255,213 @@ func (p *PlaylistBase) RemoveVideo(id string) {
 	)
 }
 
+// InsertVideo inserts the video before the one at the index, the index of len(Videos()) appends it.
+func (p *PlaylistBase) InsertVideo(id string, index int) error {
+	videos := p.Videos()
+	if index < 0 || index > len(videos) {
+		return ErrPlaylistIndex
+	}
+	if slices.Contains(videos, id) {
+		return ErrPlaylistDuplicate
+	}
+
+	p.SetVideos(slices.Insert(videos, index, id))
+
+	return nil
+}
+
+// MoveVideo moves the video from one index to another, shifting the videos in between.
+func (p *PlaylistBase) MoveVideo(from int, to int) error {
+	videos := p.Videos()
+	if from < 0 || from >= len(videos) || to < 0 || to >= len(videos) {
+		return ErrPlaylistIndex
+	}
+
+	id := videos[from]
+	videos = slices.Delete(videos, from, from+1)
+	p.SetVideos(slices.Insert(videos, to, id))
+
+	return nil
+}
+
+// ReorderVideos sets the new order of the videos, which must be the same videos the playlist has.
+func (p *PlaylistBase) ReorderVideos(ids []string) error {
+	current := slices.Clone(p.Videos())
+	reordered := slices.Clone(ids)
+	slices.Sort(current)
+	slices.Sort(reordered)
+	if !slices.Equal(current, reordered) {
+		return ErrPlaylistReorder
+	}
+
+	p.SetVideos(ids)
+
+	return nil
+}
+
+// Version is incremented on every change of the videos,
+// so the changes made for an outdated version can be rejected.
+func (p *PlaylistBase) Version() int {
+	return p.GetInt("version")
+}
+
 func (p *PlaylistBase) Preview() string {
 	return p.GetString("preview")
 }
 
+// PreviewVideo is the video the preview was made from,
+// so the preview of the smart playlist is made again when its first video changes.
+func (p *PlaylistBase) PreviewVideo() string {
+	return p.GetString("preview_video")
+}
+
+func (p *PlaylistBase) SetPreviewVideo(id string) {
+	p.Set("preview_video", id)
+}
+
+// SetPreview sets the preview of the playlist, nil removes the preview.
 func (p *PlaylistBase) SetPreview(file *filesystem.File) {
+	if file == nil {
+		p.Set("preview", "")
+		return
+	}
+
 	p.Set("preview", file)
 }
+
+// NewPlaylistPreviewVideo returns the first video of the playlist, which can be viewed by the guests,
+// so the preview doesn't disclose the videos hidden from the viewers of the playlist.
+// It returns nil if there is no such video.
+func NewPlaylistPreviewVideo(playlist Playlist) (Video, error) {
+	videos, err := playlist.OwnerVideos()
+	if err != nil {
+		return nil, err
+	}
+
+	videos, err = viewableVideos(videos, &core.RequestInfo{})
+	if err != nil || len(videos) == 0 {
+		return nil, err
+	}
+
+	return NewVideoFromId(videos[0])
+}
+
+// viewableVideos returns the ids of the videos, which can be viewed by the requester, in their order.
+func viewableVideos(ids []string, info *core.RequestInfo) ([]string, error) {
+	records, err := PocketBase.FindRecordsByIds(entities.VideosCollection, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	viewable := make(map[string]bool, len(records))
+	for _, record := range records {
+		canAccess, err := PocketBase.CanAccessRecord(record, info, record.Collection().ViewRule)
+		if err != nil {
+			return nil, err
+		}
+		viewable[record.Id] = canAccess
+	}
+
+	return slices.DeleteFunc(slices.Clone(ids), func(id string) bool {
+		return !viewable[id]
+	}), nil
+}
+
+// findSmartVideos returns the ids of the videos found by the filter and the sort,
+// which can be listed by the requester.
+func findSmartVideos(filter string, sort string, limit int, info *core.RequestInfo) ([]string, error) {
+	col, err := Collections.Get(entities.VideosCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	query := PocketBase.RecordQuery(col).Limit(int64(limit))
+	resolver := core.NewRecordFieldResolver(PocketBase, col, info, true)
+
+	if !info.HasSuperuserAuth() {
+		if col.ListRule == nil {
+			return []string{}, nil
+		}
+		if *col.ListRule != "" {
+			expr, err := search.FilterData(*col.ListRule).BuildExpr(resolver)
+			if err != nil {
+				return nil, err
+			}
+			query.AndWhere(expr)
+		}
+	}
+
+	// the filter and the sort of the owner can't use the hidden fields
+	resolver.SetAllowHiddenFields(info.HasSuperuserAuth())
+	if err = applySmartQuery(query, resolver, filter, sort); err != nil {
+		return nil, err
+	}
+	if err = resolver.UpdateQuery(query); err != nil {
+		return nil, err
+	}
+
+	records := make([]*core.Record, 0, limit)
+	if err = query.All(&records); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.Id
+	}
+	return ids, nil
+}
+
+// ValidateSmartQuery checks the filter and the sort of the smart playlist.
+func ValidateSmartQuery(filter string, sort string) error {
+	col, err := Collections.Get(entities.VideosCollection)
+	if err != nil {
+		return err
+	}
+
+	query := PocketBase.RecordQuery(col)
+	resolver := core.NewRecordFieldResolver(PocketBase, col, &core.RequestInfo{Context: core.RequestInfoContextDefault}, false)
+	if err = applySmartQuery(query, resolver, filter, sort); err != nil {
+		return fmt.Errorf("%w: %w", ErrPlaylistFilter, err)
+	}
+
+	return nil
+}
+
+// smartQueryParams are the placeholders of the time windows, which can be used in the smart playlist filters,
+// e.g. "created >= {:last30Days}".
+func smartQueryParams() dbx.Params {
+	now := types.NowDateTime()
+
+	return dbx.Params{
+		"last24Hours": now.Add(-24 * time.Hour).String(),
+		"last7Days":   now.AddDate(0, 0, -7).String(),
+		"last30Days":  now.AddDate(0, 0, -30).String(),
+		"last365Days": now.AddDate(0, 0, -365).String(),
+	}
+}
+
+func applySmartQuery(query *dbx.SelectQuery, resolver *core.RecordFieldResolver, filter string, sort string) error {
+	if filter != "" {
+		expr, err := search.FilterData(filter).BuildExpr(resolver, smartQueryParams())
+		if err != nil {
+			return err
+		}
+		query.AndWhere(expr)
+	}
+
+	if sort != "" {
+		for _, field := range search.ParseSortFromString(sort) {
+			expr, err := field.BuildExpr(resolver)
+			if err != nil {
+				return err
+			}
+			query.AndOrderBy(expr)
+		}
+	}
+
+	// the videos of the same sort keep the order they were uploaded in
+	query.AndOrderBy("[[" + entities.VideosCollection + ".created]] DESC")
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type PlaylistMember interface {
+	core.RecordProxy
+	Save() error
+	Delete() error
+	ID() string
+	Playlist() string
+	SetPlaylist(string)
+	User() string
+	SetUser(string)
+	Role() entities.PlaylistRole
+	SetRole(entities.PlaylistRole)
+}
@@ -0,0 +1,77 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type PlaylistMemberBase struct {
+	core.BaseRecordProxy
+}
+
+func NewPlaylistMember() (PlaylistMember, error) {
+	col, err := Collections.Get(entities.PlaylistMembersCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPlaylistMemberFromRecord(core.NewRecord(col)), nil
+}
+
+func NewPlaylistMemberFromRecord(record *core.Record) PlaylistMember {
+	m := &PlaylistMemberBase{}
+	m.SetProxyRecord(record)
+
+	return m
+}
+
+func NewPlaylistMemberFromUser(playlistId string, userId string) (PlaylistMember, error) {
+	record, err := PocketBase.FindFirstRecordByFilter(
+		entities.PlaylistMembersCollection,
+		"playlist = {:playlistId} && user = {:userId}",
+		dbx.Params{"playlistId": playlistId, "userId": userId},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPlaylistMemberFromRecord(record), nil
+}
+
+func (m *PlaylistMemberBase) Save() error {
+	return PocketBase.Save(m)
+}
+
+func (m *PlaylistMemberBase) Delete() error {
+	return PocketBase.Delete(m)
+}
+
+func (m *PlaylistMemberBase) ID() string {
+	return m.Id
+}
+
+func (m *PlaylistMemberBase) Playlist() string {
+	return m.GetString("playlist")
+}
+
+func (m *PlaylistMemberBase) SetPlaylist(id string) {
+	m.Set("playlist", id)
+}
+
+func (m *PlaylistMemberBase) User() string {
+	return m.GetString("user")
+}
+
+func (m *PlaylistMemberBase) SetUser(id string) {
+	m.Set("user", id)
+}
+
+func (m *PlaylistMemberBase) Role() entities.PlaylistRole {
+	return entities.PlaylistRole(m.GetString("role"))
+}
+
+func (m *PlaylistMemberBase) SetRole(role entities.PlaylistRole) {
+	m.Set("role", string(role))
+}
@@ -0,0 +1,66 @@
+package vhs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+const (
+	// TokenTypeStream is the type of the tokens of the stream URLs,
+	// which PocketBase doesn't accept for anything else.
+	TokenTypeStream = "stream"
+
+	tokenClaimVideoId = "videoId"
+)
+
+var ErrStreamToken = errors.New("invalid stream token")
+
+// NewStreamToken returns the token, which allows the auth record to stream the video only.
+// It is signed with the file token secret of the auth collection, so it is invalidated
+// the same way the file tokens are.
+func NewStreamToken(auth *core.Record, videoId string, duration time.Duration) (string, error) {
+	return security.NewJWT(
+		jwt.MapClaims{
+			core.TokenClaimType:         TokenTypeStream,
+			core.TokenClaimId:           auth.Id,
+			core.TokenClaimCollectionId: auth.Collection().Id,
+			tokenClaimVideoId:           videoId,
+		},
+		auth.TokenKey()+auth.Collection().FileToken.Secret,
+		duration,
+	)
+}
+
+// FindAuthRecordByStreamToken returns the auth record of the token, if the token is made for the video.
+func FindAuthRecordByStreamToken(token string, videoId string) (*core.Record, error) {
+	claims, err := security.ParseUnverifiedJWT(token)
+	if err != nil {
+		return nil, errors.Join(ErrStreamToken, err)
+	}
+
+	id, _ := claims[core.TokenClaimId].(string)
+	collectionId, _ := claims[core.TokenClaimCollectionId].(string)
+	tokenType, _ := claims[core.TokenClaimType].(string)
+	tokenVideoId, _ := claims[tokenClaimVideoId].(string)
+	if id == "" || collectionId == "" || tokenType != TokenTypeStream || tokenVideoId != videoId {
+		return nil, ErrStreamToken
+	}
+
+	record, err := PocketBase.FindRecordById(collectionId, id)
+	if err != nil {
+		return nil, errors.Join(ErrStreamToken, err)
+	}
+	if !record.Collection().IsAuth() {
+		return nil, ErrStreamToken
+	}
+
+	if _, err = security.ParseJWT(token, record.TokenKey()+record.Collection().FileToken.Secret); err != nil {
+		return nil, errors.Join(ErrStreamToken, err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,337 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"vhs/internal/vhs"
+	"vhs/internal/vhs/entities/dto"
+
+	"github.com/gorilla/websocket"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type uploadResponse struct {
+	Type    string `json:"type"`
+	VideoId string `json:"videoId"`
+	Offset  int    `json:"offset"`
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+}
+
+// newTestUploadConn connects to the websocket upload handler.
+func newTestUploadConn(t *testing.T) *websocket.Conn {
+	h := newTestHandlers()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := &core.RequestEvent{App: PocketBase}
+		e.Request = r
+		e.Response = w
+		h.UploadVideoHandler(e)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func sendUploadMessage(t *testing.T, c *websocket.Conn, message any) *uploadResponse {
+	if err := c.WriteJSON(message); err != nil {
+		t.Fatal(err)
+	}
+
+	return readUploadResponse(t, c)
+}
+
+func sendUploadPart(t *testing.T, c *websocket.Conn, p []byte) *uploadResponse {
+	if err := c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		t.Fatal(err)
+	}
+
+	return readUploadResponse(t, c)
+}
+
+func readUploadResponse(t *testing.T, c *websocket.Conn) *uploadResponse {
+	res := &uploadResponse{}
+	if err := c.ReadJSON(res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestUploadChecksums(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	ffmpeg := vhs.FFmpeg
+	defer func() { vhs.FFmpeg = ffmpeg }()
+	vhs.FFmpeg = newFakeRunner(t)
+
+	jobs := vhs.Jobs
+	defer func() { vhs.Jobs = jobs }()
+	enqueued := make(chan string, 1)
+	vhs.Jobs = &testJobQueue{enqueue: func(videoId string, _ string) error {
+		enqueued <- videoId
+		return nil
+	}}
+
+	user := newTestUser(t)
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := readTestAsset(t, "assets/black_30m.mp4", 2048)
+	c := newTestUploadConn(t)
+
+	res := sendUploadMessage(t, c, map[string]any{"type": "start", "size": len(p), "name": "clip.mp4", "token": token})
+	if res.Error != "" || res.VideoId == "" {
+		t.Fatalf("expected the upload to start, got %+v", res)
+	}
+	videoId := res.VideoId
+	t.Cleanup(func() {
+		if video, err := vhs.NewVideoFromId(videoId); err == nil {
+			video.Delete()
+		}
+	})
+
+	// the part which doesn't match its checksum is discarded
+	crc := crc32.Checksum(p[:1024], crc32.MakeTable(crc32.Castagnoli)) + 1
+	sendUploadMessage(t, c, map[string]any{"type": "part", "algorithm": "crc32c", "checksum": hex.EncodeToString([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)})})
+	res = sendUploadPart(t, c, p[:1024])
+	if res.Code != vhs.UploadErrorCodeChecksum || res.Offset != 0 {
+		t.Fatalf("expected %s error at offset 0, got %+v", vhs.UploadErrorCodeChecksum, res)
+	}
+
+	for _, part := range [][]byte{p[:1024], p[1024:]} {
+		sum := sha256.Sum256(part)
+		sendUploadMessage(t, c, map[string]any{"type": "part", "algorithm": "sha256", "checksum": hex.EncodeToString(sum[:])})
+		if res = sendUploadPart(t, c, part); res.Error != "" {
+			t.Fatalf("expected the part to be accepted, got %+v", res)
+		}
+	}
+	if res.Offset != len(p) {
+		t.Fatalf("expected offset %d, got %d", len(p), res.Offset)
+	}
+
+	// the file which doesn't match the hash is discarded, but the upload can be retried
+	wrong := sha256.Sum256(p[1:])
+	res = sendUploadMessage(t, c, map[string]any{"type": "end", "checksum": hex.EncodeToString(wrong[:])})
+	if res.Code != vhs.UploadErrorCodeHash || res.Offset != 0 {
+		t.Fatalf("expected %s error at offset 0, got %+v", vhs.UploadErrorCodeHash, res)
+	}
+	if _, err = vhs.NewUploadSessionFromVideoId(videoId); err != nil {
+		t.Fatalf("expected the session to be kept, got %v", err)
+	}
+	if _, err = vhs.NewVideoFromId(videoId); err != nil {
+		t.Fatalf("expected the video to be kept, got %v", err)
+	}
+
+	if res = sendUploadPart(t, c, p); res.Error != "" || res.Offset != len(p) {
+		t.Fatalf("expected the file to be sent again, got %+v", res)
+	}
+	sum := sha256.Sum256(p)
+	res = sendUploadMessage(t, c, map[string]any{"type": "end", "checksum": hex.EncodeToString(sum[:])})
+	if res.Type != vhs.UploadVideoMessageEnd || res.Error != "" {
+		t.Fatalf("expected the upload to end, got %+v", res)
+	}
+	// the upload is queued once the response is sent
+	if id := <-enqueued; id != videoId {
+		t.Errorf("expected the upload to be queued, got %q", id)
+	}
+
+	video, err := vhs.NewVideoFromId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.Sha256() != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the hash of the file on the video, got %q", video.Sha256())
+	}
+}
+
+func TestSetVideoPreview(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	ffmpeg := vhs.FFmpeg
+	defer func() { vhs.FFmpeg = ffmpeg }()
+	runner := newFakeRunner(t)
+	vhs.FFmpeg = runner
+
+	video := newTestVideo(t)
+	if err := newTestProcessor(t, video, runner).Process(); err != nil {
+		t.Fatal(err)
+	}
+	owner := video.User()
+	other := newTestUser(t)
+	app := vhs.NewAppMock(&vhs.AppBaseMock{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+
+	candidates, err := app.PreviewCandidates(video.ID(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != vhs.PreviewCandidatesCount {
+		t.Fatalf("expected %d candidates, got %d", vhs.PreviewCandidatesCount, len(candidates))
+	}
+
+	preview := video.Preview()
+	if err = app.SetVideoPreview(video.ID(), owner, &dto.VideoPreview{Candidate: candidates[1].File}); err != nil {
+		t.Fatal(err)
+	}
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.Preview() == preview || !video.PreviewIsSet() {
+		t.Errorf("expected the preview to be set from the candidate, got %q", video.Preview())
+	}
+
+	preview = video.Preview()
+	second := 60.0
+	if err = app.SetVideoPreview(video.ID(), owner, &dto.VideoPreview{Time: &second}); err != nil {
+		t.Fatal(err)
+	}
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.Preview() == preview {
+		t.Error("expected the preview to be set from the frame")
+	}
+
+	beyond := video.Duration() + 1
+	negative := -1.0
+	tests := []struct {
+		name   string
+		userId string
+		data   *dto.VideoPreview
+		err    error
+	}{
+		{"unknown candidate", owner, &dto.VideoPreview{Candidate: "missing.jpg"}, vhs.ErrPreviewCandidate},
+		{"beyond duration", owner, &dto.VideoPreview{Time: &beyond}, vhs.ErrPreviewTime},
+		{"negative time", owner, &dto.VideoPreview{Time: &negative}, vhs.ErrPreviewTime},
+		{"nothing", owner, &dto.VideoPreview{}, vhs.ErrPreviewCandidate},
+		{"another user", other.Id, &dto.VideoPreview{Candidate: candidates[0].File}, vhs.ErrVideoAccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := app.SetVideoPreview(video.ID(), tt.userId, tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	preview = video.Preview()
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.Preview() != preview {
+		t.Error("expected the rejected requests not to change the preview")
+	}
+
+	if _, err = app.PreviewCandidates(video.ID(), other.Id); !errors.Is(err, vhs.ErrVideoAccess) {
+		t.Errorf("expected access error for the candidates of another user, got %v", err)
+	}
+
+	// the video of another user isn't disclosed by the endpoints
+	h := newTestHandlers()
+	req := httptest.NewRequest(http.MethodPost, "/api/video/"+video.ID()+"/preview", strings.NewReader(`{"time": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("videoId", video.ID())
+	if status, _ := serveTestRequest(t, h.SetVideoPreviewHandler, req, other); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", status)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/video/"+video.ID()+"/preview/candidates", nil)
+	req.SetPathValue("videoId", video.ID())
+	if status, _ := serveTestRequest(t, h.PreviewCandidatesHandler, req, other); status != http.StatusNotFound {
+		t.Errorf("expected 404 for the candidates of another user, got %d", status)
+	}
+
+	user, err := PocketBase.FindRecordById("users", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/video/"+video.ID()+"/preview", strings.NewReader(fmt.Sprintf(`{"time": %v}`, beyond)))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("videoId", video.ID())
+	if status, _ := serveTestRequest(t, h.SetVideoPreviewHandler, req, user); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for the time beyond the duration, got %d", status)
+	}
+}
+
+func TestUploadStartedTwice(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	limit := vhs.Config.MaxUploadsPerUser
+	defer func() { vhs.Config.MaxUploadsPerUser = limit }()
+	vhs.Config.MaxUploadsPerUser = 2
+
+	user := newTestUser(t)
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := map[string]any{"type": "start", "size": 1024, "name": "clip.mp4", "token": token}
+	c := newTestUploadConn(t)
+	res := sendUploadMessage(t, c, start)
+	if res.Error != "" || res.VideoId == "" {
+		t.Fatalf("expected the upload to start, got %+v", res)
+	}
+	videoId := res.VideoId
+	t.Cleanup(func() {
+		if video, err := vhs.NewVideoFromId(videoId); err == nil {
+			video.Delete()
+		}
+	})
+
+	res = sendUploadMessage(t, c, start)
+	if res.Code != vhs.UploadErrorCodeInProgress {
+		t.Fatalf("expected %s error for the second start, got %+v", vhs.UploadErrorCodeInProgress, res)
+	}
+	res = sendUploadMessage(t, c, map[string]any{"type": "start", "videoId": videoId, "token": token})
+	if res.Code != vhs.UploadErrorCodeInProgress {
+		t.Fatalf("expected %s error for the resume, got %+v", vhs.UploadErrorCodeInProgress, res)
+	}
+	c.Close()
+
+	// the upload is released once the connection is closed, so it can be resumed
+	// and it doesn't count against the limit
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c = newTestUploadConn(t)
+		res = sendUploadMessage(t, c, map[string]any{"type": "start", "videoId": videoId, "token": token})
+		if res.Code != vhs.UploadErrorCodeInProgress || time.Now().After(deadline) {
+			break
+		}
+		c.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if res.Error != "" || res.VideoId != videoId {
+		t.Fatalf("expected the upload to be resumed, got %+v", res)
+	}
+
+	other := newTestUploadConn(t)
+	res = sendUploadMessage(t, other, start)
+	if res.Error != "" {
+		t.Fatalf("expected another upload to start, got %+v", res)
+	}
+	otherId := res.VideoId
+	t.Cleanup(func() {
+		if video, err := vhs.NewVideoFromId(otherId); err == nil {
+			video.Delete()
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"vhs/internal/vhs"
+	"vhs/internal/vhs/entities"
+)
+
+func TestExportPlaylistToGuest(t *testing.T) {
+	closed := newTestVideo(t)
+	public := newTestVideo(t)
+	public.SetStatus(entities.StatusPublic)
+	if err := public.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	newPlaylist := func(status entities.Status) vhs.Playlist {
+		playlist := newTestPlaylist(t, closed.ID(), public.ID())
+		playlist.SetName("export")
+		playlist.SetUser(public.User())
+		playlist.SetStatus(status)
+		if err := playlist.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		return playlist
+	}
+
+	h := newTestHandlers()
+	// the guest sends no auth, as the external players do
+	export := func(playlist vhs.Playlist, format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/playlist/"+playlist.ID()+"/export?format="+format, nil)
+		req.SetPathValue("playlistId", playlist.ID())
+
+		return serveTestResponse(t, h.ExportPlaylistHandler, req, nil)
+	}
+
+	playlist := newPlaylist(entities.StatusPublic)
+	rec := export(playlist, "json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var res entities.PlaylistExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 || res.Items[0].Id != public.ID() {
+		t.Fatalf("expected the public video only, got %+v", res.Items)
+	}
+	if res.Items[0].Token != "" || !strings.HasSuffix(res.Items[0].URL, "/api/video/"+public.ID()+"/stream") {
+		t.Errorf("expected the stream URL without a token, got %q", res.Items[0].URL)
+	}
+
+	rec = export(playlist, "m3u8")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for m3u8, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.HasPrefix(body, "#EXTM3U\n") || strings.Contains(body, closed.ID()) {
+		t.Errorf("expected the m3u8 playlist without the closed video, got %q", body)
+	}
+
+	if rec = export(newPlaylist(entities.StatusClosed), "m3u8"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the closed playlist, got %d", rec.Code)
+	}
+}
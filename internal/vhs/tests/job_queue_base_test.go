@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"vhs/internal/vhs"
+	"vhs/internal/vhs/entities"
+	"vhs/pkg/ffhelp"
+)
+
+func newTestJobQueue(t *testing.T) *vhs.JobQueueBase {
+	// no workers, the jobs are run by the test
+	q, ok := vhs.NewJobQueue(slog.New(slog.NewTextHandler(io.Discard, nil)), 0).(*vhs.JobQueueBase)
+	if !ok {
+		t.Fatal("expected *vhs.JobQueueBase")
+	}
+
+	return q
+}
+
+func newTestJobFile(t *testing.T) string {
+	f, err := os.CreateTemp(t.TempDir(), "video_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(readTestAsset(t, "assets/black_30m.mp4", 64<<10)); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func newTestJob(t *testing.T, videoId string, state entities.JobState, attempts int) vhs.Job {
+	job, err := vhs.NewJob()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job.SetVideo(videoId)
+	job.SetFile(newTestJobFile(t))
+	job.SetState(state)
+	job.SetAttempts(attempts)
+	if err = job.Save(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { PocketBase.Delete(job.ProxyRecord()) })
+
+	return job
+}
+
+func findTestJob(t *testing.T, id string) vhs.Job {
+	record, err := PocketBase.FindRecordById(entities.JobsCollection, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vhs.NewJobFromRecord(record)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestJobQueueRequeuesInterruptedJobs(t *testing.T) {
+	video := newTestVideo(t)
+	interrupted := newTestJob(t, video.ID(), entities.JobStateRunning, 1)
+	exhausted := newTestJob(t, video.ID(), entities.JobStateRunning, vhs.JobMaxAttempts)
+
+	if err := newTestJobQueue(t).Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	job := findTestJob(t, interrupted.ID())
+	if job.State() != entities.JobStateQueued {
+		t.Errorf("expected the interrupted job to be queued, got %s", job.State())
+	}
+	if job.LastError() == "" {
+		t.Error("expected the interruption to be recorded on the job")
+	}
+	if !fileExists(job.File()) {
+		t.Error("expected the file of the requeued job to be kept")
+	}
+
+	job = findTestJob(t, exhausted.ID())
+	if job.State() != entities.JobStateFailed {
+		t.Errorf("expected the job out of attempts to fail, got %s", job.State())
+	}
+	if fileExists(job.File()) {
+		t.Error("expected the file of the failed job to be removed")
+	}
+}
+
+func TestJobQueueMaxAttempts(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	ffmpeg := vhs.FFmpeg
+	defer func() { vhs.FFmpeg = ffmpeg }()
+
+	file := newTestJobFile(t)
+	attempts := 0
+	runner := newFakeRunner(t)
+	runner.Fail = func(args []string) error {
+		attempts++
+		if !fileExists(file) {
+			t.Errorf("expected the file to be kept for the attempt %d", attempts)
+		}
+		return &ffhelp.Error{Command: "ffprobe", Args: args, ExitCode: 1, Stderr: file + ": Invalid data"}
+	}
+	vhs.FFmpeg = runner
+
+	video := newTestVideo(t)
+	q := newTestJobQueue(t)
+	if err := q.Enqueue(video.ID(), file); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := vhs.NewJobsFromState(entities.JobStateQueued, 0)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected 1 queued job, got %d: %v", len(jobs), err)
+	}
+	t.Cleanup(func() { PocketBase.Delete(jobs[0].ProxyRecord()) })
+
+	for i := 1; i <= vhs.JobMaxAttempts; i++ {
+		ok, err := q.RunNext()
+		if err != nil || !ok {
+			t.Fatalf("expected the attempt %d to run, got %v", i, err)
+		}
+
+		job := findTestJob(t, jobs[0].ID())
+		if job.Attempts() != i {
+			t.Errorf("expected %d attempts, got %d", i, job.Attempts())
+		}
+
+		if err = video.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+
+		if i < vhs.JobMaxAttempts {
+			if job.State() != entities.JobStateQueued {
+				t.Errorf("expected the job to be requeued after the attempt %d, got %s", i, job.State())
+			}
+			if video.ProcessingState() != entities.ProcessingStateQueued {
+				t.Errorf("expected the video to be queued after the attempt %d, got %s", i, video.ProcessingState())
+			}
+			if !fileExists(file) {
+				t.Errorf("expected the file to be kept after the attempt %d", i)
+			}
+			continue
+		}
+
+		if job.State() != entities.JobStateFailed {
+			t.Errorf("expected the job to fail, got %s", job.State())
+		}
+		if video.ProcessingState() != entities.ProcessingStateFailed {
+			t.Errorf("expected the video to fail, got %s", video.ProcessingState())
+		}
+		if video.ProcessingError() != "ffprobe exited with code 1" {
+			t.Errorf("expected only the summary of the ffmpeg error, got %q", video.ProcessingError())
+		}
+		if fileExists(file) {
+			t.Error("expected the file to be removed after the last attempt")
+		}
+	}
+
+	if ok, err := q.RunNext(); ok || err != nil {
+		t.Errorf("expected no job to run after the failure, got %v, %v", ok, err)
+	}
+}
+
+func TestJobQueueRemovesFileOnSuccess(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	ffmpeg := vhs.FFmpeg
+	defer func() { vhs.FFmpeg = ffmpeg }()
+	vhs.FFmpeg = newFakeRunner(t)
+
+	job := newTestJob(t, newTestVideo(t).ID(), entities.JobStateQueued, 0)
+
+	ok, err := newTestJobQueue(t).RunNext()
+	if err != nil || !ok {
+		t.Fatalf("expected the job to run, got %v", err)
+	}
+
+	job = findTestJob(t, job.ID())
+	if job.State() != entities.JobStateSucceeded {
+		t.Errorf("expected the job to succeed, got %s: %s", job.State(), job.LastError())
+	}
+	if fileExists(job.File()) {
+		t.Error("expected the file to be removed after the success")
+	}
+}
@@ -0,0 +1,504 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"vhs/internal/vhs"
+	"vhs/internal/vhs/entities"
+	"vhs/internal/vhs/entities/dto"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+func newTestPlaylist(t *testing.T, videos ...string) vhs.Playlist {
+	col, err := Collections.Get(entities.PlaylistsCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := vhs.NewPlaylistFromRecord(core.NewRecord(col))
+	playlist.SetVideos(videos)
+
+	return playlist
+}
+
+func TestPlaylistInsertVideo(t *testing.T) {
+	playlist := newTestPlaylist(t, "a", "b")
+	version := playlist.Version()
+
+	if err := playlist.InsertVideo("c", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := playlist.InsertVideo("d", 3); err != nil {
+		t.Fatal(err)
+	}
+	if videos := playlist.Videos(); !slices.Equal(videos, []string{"a", "c", "b", "d"}) {
+		t.Errorf("expected a c b d, got %v", videos)
+	}
+	if playlist.Version() != version+2 {
+		t.Errorf("expected version %d, got %d", version+2, playlist.Version())
+	}
+
+	if err := playlist.InsertVideo("e", 5); !errors.Is(err, vhs.ErrPlaylistIndex) {
+		t.Errorf("expected index error, got %v", err)
+	}
+	if err := playlist.InsertVideo("a", 0); !errors.Is(err, vhs.ErrPlaylistDuplicate) {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestPlaylistMoveVideo(t *testing.T) {
+	playlist := newTestPlaylist(t, "a", "b", "c", "d")
+
+	if err := playlist.MoveVideo(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if videos := playlist.Videos(); !slices.Equal(videos, []string{"b", "c", "a", "d"}) {
+		t.Errorf("expected b c a d, got %v", videos)
+	}
+
+	if err := playlist.MoveVideo(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if videos := playlist.Videos(); !slices.Equal(videos, []string{"d", "b", "c", "a"}) {
+		t.Errorf("expected d b c a, got %v", videos)
+	}
+
+	if err := playlist.MoveVideo(0, 4); !errors.Is(err, vhs.ErrPlaylistIndex) {
+		t.Errorf("expected index error, got %v", err)
+	}
+}
+
+func TestPlaylistReorderVideos(t *testing.T) {
+	playlist := newTestPlaylist(t, "a", "b", "c")
+
+	if err := playlist.ReorderVideos([]string{"c", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if videos := playlist.Videos(); !slices.Equal(videos, []string{"c", "a", "b"}) {
+		t.Errorf("expected c a b, got %v", videos)
+	}
+
+	for _, videos := range [][]string{
+		{"a", "b"},
+		{"a", "b", "c", "d"},
+		{"a", "b", "b"},
+	} {
+		if err := playlist.ReorderVideos(videos); !errors.Is(err, vhs.ErrPlaylistReorder) {
+			t.Errorf("expected reorder error for %v, got %v", videos, err)
+		}
+	}
+}
+
+func TestVisiblePlaylistsFromVideoId(t *testing.T) {
+	video := newTestVideo(t)
+	owner := newTestUser(t)
+	viewer := newTestUser(t)
+
+	statuses := []entities.Status{entities.StatusPublic, entities.StatusLink, entities.StatusClosed}
+	ids := make(map[entities.Status]string, len(statuses))
+	for _, status := range statuses {
+		playlist := newTestPlaylist(t, video.ID())
+		playlist.SetName(string(status))
+		playlist.SetUser(owner.Id)
+		playlist.SetStatus(status)
+		if err := playlist.Save(); err != nil {
+			t.Fatal(err)
+		}
+		ids[status] = playlist.ID()
+	}
+
+	playlists, err := vhs.NewVisiblePlaylistsFromVideoId(video.ID(), viewer.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlists) != 1 || playlists[0].ID() != ids[entities.StatusPublic] {
+		t.Errorf("expected only the public playlist, got %d playlists", len(playlists))
+	}
+
+	playlists, err = vhs.NewVisiblePlaylistsFromVideoId(video.ID(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlists) != 1 || playlists[0].ID() != ids[entities.StatusPublic] {
+		t.Errorf("expected only the public playlist for a guest, got %d playlists", len(playlists))
+	}
+
+	playlists, err = vhs.NewVisiblePlaylistsFromVideoId(video.ID(), owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlists) != len(statuses) {
+		t.Errorf("expected all %d playlists of the owner, got %d", len(statuses), len(playlists))
+	}
+}
+
+func TestPlaylistRole(t *testing.T) {
+	owner := newTestUser(t)
+	editor := newTestUser(t)
+	stranger := newTestUser(t)
+
+	playlist := newTestPlaylist(t)
+	playlist.SetName("members")
+	playlist.SetUser(owner.Id)
+	playlist.SetStatus(entities.StatusClosed)
+	if err := playlist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	member, err := vhs.NewPlaylistMember()
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.SetPlaylist(playlist.ID())
+	member.SetUser(editor.Id)
+	member.SetRole(entities.PlaylistRoleEditor)
+	if err = member.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := map[string]entities.PlaylistRole{
+		owner.Id:    entities.PlaylistRoleOwner,
+		editor.Id:   entities.PlaylistRoleEditor,
+		stranger.Id: "",
+		"":          "",
+	}
+	for userId, expected := range roles {
+		role, err := playlist.Role(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != expected {
+			t.Errorf("expected role %q of user %q, got %q", expected, userId, role)
+		}
+	}
+
+	if !entities.PlaylistRoleEditor.Includes(entities.PlaylistRoleContributor) {
+		t.Error("expected editor to include contributor")
+	}
+	if entities.PlaylistRoleContributor.Includes(entities.PlaylistRoleEditor) {
+		t.Error("expected contributor not to include editor")
+	}
+	if entities.PlaylistRole("").Includes(entities.PlaylistRoleViewer) {
+		t.Error("expected no role not to include viewer")
+	}
+}
+
+func TestVisiblePlaylistsOfMember(t *testing.T) {
+	video := newTestVideo(t)
+	owner := newTestUser(t)
+	viewer := newTestUser(t)
+
+	playlist := newTestPlaylist(t, video.ID())
+	playlist.SetName("closed")
+	playlist.SetUser(owner.Id)
+	playlist.SetStatus(entities.StatusClosed)
+	if err := playlist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	member, err := vhs.NewPlaylistMember()
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.SetPlaylist(playlist.ID())
+	member.SetUser(viewer.Id)
+	member.SetRole(entities.PlaylistRoleViewer)
+	if err = member.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	playlists, err := vhs.NewVisiblePlaylistsFromVideoId(video.ID(), viewer.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlists) != 1 || playlists[0].ID() != playlist.ID() {
+		t.Errorf("expected the closed playlist of the member, got %d playlists", len(playlists))
+	}
+}
+
+func TestSmartPlaylistVideos(t *testing.T) {
+	owner := newTestUser(t)
+	stranger := newTestUser(t)
+
+	videos, err := Collections.Get(entities.VideosCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[string]string)
+	for name, status := range map[string]entities.Status{
+		"standup a": entities.StatusPublic,
+		"standup b": entities.StatusPublic,
+		"standup c": entities.StatusClosed,
+		"retro":     entities.StatusPublic,
+	} {
+		video := vhs.NewVideoFromRecord(core.NewRecord(videos))
+		video.SetName(name)
+		video.SetStatus(status)
+		video.SetUser(owner.Id)
+		if err = video.Save(); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = video.ID()
+	}
+
+	playlist := newTestPlaylist(t)
+	playlist.SetKind(entities.PlaylistKindSmart)
+	playlist.SetUser(owner.Id)
+	playlist.SetFilter(`user = "` + owner.Id + `" && name ~ "standup" && created >= {:last30Days}`)
+	playlist.SetSort("-name")
+
+	resolve := func(user *core.Record) []string {
+		videos, err := playlist.ResolveVideos(&core.RequestInfo{Context: core.RequestInfoContextDefault, Auth: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return videos
+	}
+
+	expected := []string{ids["standup c"], ids["standup b"], ids["standup a"]}
+	owned, err := playlist.OwnerVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(owned, expected) {
+		t.Errorf("expected the videos of the owner %v, got %v", expected, owned)
+	}
+	if videos := resolve(stranger); !slices.Equal(videos, expected[1:]) {
+		t.Errorf("expected the public videos %v, got %v", expected[1:], videos)
+	}
+
+	playlist.SetLimit(1)
+	if videos := resolve(owner); !slices.Equal(videos, expected[:1]) {
+		t.Errorf("expected the first video %v, got %v", expected[:1], videos)
+	}
+}
+
+func TestValidateSmartQuery(t *testing.T) {
+	if err := vhs.ValidateSmartQuery(`name ~ "standup" && created >= {:last7Days}`, "-created,name"); err != nil {
+		t.Errorf("expected valid query, got %v", err)
+	}
+	if err := vhs.ValidateSmartQuery(`name ~`, ""); !errors.Is(err, vhs.ErrPlaylistFilter) {
+		t.Errorf("expected filter error, got %v", err)
+	}
+	if err := vhs.ValidateSmartQuery("", "unknown"); !errors.Is(err, vhs.ErrPlaylistFilter) {
+		t.Errorf("expected sort error, got %v", err)
+	}
+}
+
+func TestExportPlaylist(t *testing.T) {
+	owner := newTestUser(t)
+
+	videos, err := Collections.Get(entities.VideosCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 0, 2)
+	for _, status := range []entities.Status{entities.StatusClosed, entities.StatusPublic} {
+		video := vhs.NewVideoFromRecord(core.NewRecord(videos))
+		video.SetName(string(status))
+		video.SetStatus(status)
+		video.SetUser(owner.Id)
+		video.SetDuration(12.5)
+		if err = video.Save(); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, video.ID())
+	}
+
+	playlist := newTestPlaylist(t, ids...)
+	playlist.SetName("export")
+	playlist.SetUser(owner.Id)
+	playlist.SetStatus(entities.StatusPublic)
+	if err = playlist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &vhs.AppBase{}
+
+	export, err := app.ExportPlaylist(playlist.ID(), &core.RequestInfo{Context: core.RequestInfoContextDefault})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Items) != 1 || export.Items[0].Id != ids[1] || export.Items[0].Duration != 12.5 {
+		t.Errorf("expected the public video only, got %d videos", len(export.Items))
+	}
+	if export.Items[0].Token != "" {
+		t.Error("expected no token for a guest")
+	}
+
+	export, err = app.ExportPlaylist(playlist.ID(), &core.RequestInfo{Context: core.RequestInfoContextDefault, Auth: owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := make([]string, len(export.Items))
+	for i, item := range export.Items {
+		exported[i] = item.Id
+	}
+	if !slices.Equal(exported, ids) {
+		t.Errorf("expected videos %v, got %v", ids, exported)
+	}
+
+	if export.Items[1].Token != "" {
+		t.Error("expected no token for the public video of the public playlist")
+	}
+
+	token := export.Items[0].Token
+	record, err := vhs.FindAuthRecordByStreamToken(token, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Id != owner.Id {
+		t.Errorf("expected token of the owner, got %s", record.Id)
+	}
+	if _, err = vhs.FindAuthRecordByStreamToken(token, ids[1]); !errors.Is(err, vhs.ErrStreamToken) {
+		t.Errorf("expected token not to allow another video, got %v", err)
+	}
+	if _, err = PocketBase.FindAuthRecordByToken(token); err == nil {
+		t.Error("expected token not to be accepted by PocketBase")
+	}
+}
+
+func TestPlaylistPreviewVideo(t *testing.T) {
+	closed := newTestVideo(t)
+	public := newTestVideo(t)
+	public.SetStatus(entities.StatusPublic)
+	if err := public.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := newTestPlaylist(t, closed.ID(), public.ID())
+	playlist.SetStatus(entities.StatusPublic)
+
+	// the closed video comes first, but its preview would be shown to the viewers of the playlist
+	video, err := vhs.NewPlaylistPreviewVideo(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video == nil || video.ID() != public.ID() {
+		t.Errorf("expected the public video, got %v", video)
+	}
+
+	public.SetStatus(entities.StatusClosed)
+	if err = public.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if video, err = vhs.NewPlaylistPreviewVideo(playlist); err != nil || video != nil {
+		t.Errorf("expected no preview video, got %v, %v", video, err)
+	}
+}
+
+func TestSmartPlaylistErrors(t *testing.T) {
+	playlist := newTestPlaylist(t)
+	playlist.SetKind(entities.PlaylistKindSmart)
+	playlist.SetUser("missing")
+
+	if _, err := playlist.OwnerVideos(); err == nil {
+		t.Error("expected an error for the playlist without the owner")
+	}
+	if _, err := vhs.NewPlaylistPreviewVideo(playlist); err == nil {
+		t.Error("expected the error to be returned by the preview")
+	}
+
+	playlist.SetUser(newTestUser(t).Id)
+	playlist.SetFilter("missing_field = 1")
+	if _, err := playlist.OwnerVideos(); err == nil {
+		t.Error("expected an error for the invalid filter")
+	}
+}
+
+func TestSmartPlaylistsFromVideo(t *testing.T) {
+	closed := newTestVideo(t)
+	previewed := newTestVideo(t)
+	public := newTestVideo(t)
+	public.SetStatus(entities.StatusPublic)
+	if err := public.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := newTestUser(t)
+	smart := newTestPlaylist(t)
+	smart.SetName("smart")
+	smart.SetUser(owner.Id)
+	smart.SetKind(entities.PlaylistKindSmart)
+	smart.SetPreviewVideo(previewed.ID())
+	manual := newTestPlaylist(t, public.ID())
+	manual.SetName("manual")
+	manual.SetUser(owner.Id)
+	for _, playlist := range []vhs.Playlist{smart, manual} {
+		playlist.SetStatus(entities.StatusClosed)
+		if err := playlist.Save(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { playlist.Delete() })
+	}
+
+	contains := func(video vhs.Video) bool {
+		playlists, err := vhs.NewSmartPlaylistsFromVideo(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.ContainsFunc(playlists, func(p vhs.Playlist) bool { return p.ID() == smart.ID() })
+	}
+
+	// only the public videos can become the preview
+	if contains(closed) {
+		t.Error("expected the closed video not to change the preview")
+	}
+	if !contains(previewed) {
+		t.Error("expected the preview video to change the preview, e.g. once it's closed")
+	}
+	if !contains(public) {
+		t.Error("expected the public video to change the preview")
+	}
+
+	playlists, err := vhs.NewSmartPlaylistsFromVideo(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, playlist := range playlists {
+		if playlist.Kind() != entities.PlaylistKindSmart {
+			t.Errorf("expected only smart playlists, got %s", playlist.ID())
+		}
+	}
+}
+
+func TestUpdateVideoKeepsPlaylistPositions(t *testing.T) {
+	video := newTestVideo(t)
+	other := newTestVideo(t)
+
+	playlist := newTestPlaylist(t, video.ID(), other.ID())
+	playlist.SetName("updated")
+	playlist.SetUser(video.User())
+	playlist.SetStatus(entities.StatusClosed)
+	if err := playlist.Save(); err != nil {
+		t.Fatal(err)
+	}
+	version := playlist.Version()
+
+	app := vhs.NewAppMock(&vhs.AppBaseMock{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	for range 2 {
+		data := &dto.VideoUpdate{Preview: &filesystem.File{}, PlaylistIds: []string{playlist.ID()}}
+		if err := app.UpdateVideo(video.ID(), video.User(), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	playlist, err := vhs.NewPlaylistFromId(playlist.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if videos := playlist.Videos(); !slices.Equal(videos, []string{video.ID(), other.ID()}) {
+		t.Errorf("expected the video to keep its position, got %v", videos)
+	}
+	if playlist.Version() != version {
+		t.Errorf("expected version %d, got %d", version, playlist.Version())
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"vhs/internal/http/handlers/v1"
+	"vhs/internal/vhs"
+	"vhs/pkg/ffhelp"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestStreamVideo returns the closed video with the given streaming files in its storage.
+func newTestStreamVideo(t *testing.T, files map[string]string) (vhs.Video, *core.Record) {
+	video := newTestVideo(t)
+
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for key, content := range files {
+		if err = fs.Upload([]byte(content), video.BaseFilesPath()+"/"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owner, err := PocketBase.FindRecordById("users", video.User())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return video, owner
+}
+
+// streamRequest is the request of a streaming file of the video, with the stream token if it's given.
+func streamRequest(video vhs.Video, dir string, name string, token string) *http.Request {
+	target := "/api/video/" + video.ID() + "/" + dir + "/" + name
+	if token != "" {
+		target += "?token=" + url.QueryEscape(token)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetPathValue("videoId", video.ID())
+	req.SetPathValue("path", name)
+
+	return req
+}
+
+func TestServeHLS(t *testing.T) {
+	video, owner := newTestStreamVideo(t, map[string]string{
+		vhs.HLSFilesDir + "/" + ffhelp.HLSMasterPlaylist:     "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n360p/" + ffhelp.HLSMediaPlaylist + "\n",
+		vhs.HLSFilesDir + "/360p/" + ffhelp.HLSMediaPlaylist: "#EXTM3U\n#EXTINF:6.0,\nseg00000.ts\n#EXT-X-ENDLIST\n",
+		vhs.HLSFilesDir + "/360p/seg00000.ts":                "segment",
+		vhs.DASHFilesDir + "/" + ffhelp.DASHManifest:         "<MPD></MPD>",
+		"secret.txt": "secret",
+	})
+	h := newTestHandlers()
+
+	rec := serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", "360p/seg00000.ts", ""), owner)
+	if rec.Code != http.StatusOK || rec.Body.String() != "segment" {
+		t.Fatalf("expected the segment for the owner, got %d %q", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != handlers.HLSSegmentContentType {
+		t.Errorf("expected %s, got %s", handlers.HLSSegmentContentType, contentType)
+	}
+	// the auth token isn't passed to the playlist
+	rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", ffhelp.HLSMasterPlaylist, ""), owner)
+	if !strings.Contains(rec.Body.String(), "\n360p/"+ffhelp.HLSMediaPlaylist+"\n") {
+		t.Errorf("expected the playlist as is, got %q", rec.Body.String())
+	}
+
+	if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", ffhelp.HLSMasterPlaylist, ""), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a guest, got %d", rec.Code)
+	}
+
+	token, err := vhs.NewStreamToken(owner, video.ID(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := url.QueryEscape(token)
+
+	rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", ffhelp.HLSMasterPlaylist, token), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the stream token, got %d", rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != handlers.HLSPlaylistContentType {
+		t.Errorf("expected %s, got %s", handlers.HLSPlaylistContentType, contentType)
+	}
+	expected := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n360p/" + ffhelp.HLSMediaPlaylist + "?token=" + escaped + "\n"
+	if rec.Body.String() != expected {
+		t.Errorf("expected the URIs with the token\n%q\ngot\n%q", expected, rec.Body.String())
+	}
+
+	rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", "360p/"+ffhelp.HLSMediaPlaylist, token), nil)
+	expected = "#EXTM3U\n#EXTINF:6.0,\nseg00000.ts?token=" + escaped + "\n#EXT-X-ENDLIST\n"
+	if rec.Body.String() != expected {
+		t.Errorf("expected the segments with the token\n%q\ngot\n%q", expected, rec.Body.String())
+	}
+	if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", "360p/seg00000.ts", token), nil); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the segment with the stream token, got %d", rec.Code)
+	}
+
+	expired, err := vhs.NewStreamToken(owner, video.ID(), -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", ffhelp.HLSMasterPlaylist, expired), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the expired token, got %d", rec.Code)
+	}
+
+	// the owner of both videos can't pass the token of one video to the other
+	other := newTestVideo(t)
+	other.SetUser(owner.Id)
+	if err = other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := vhs.NewStreamToken(owner, other.ID(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", ffhelp.HLSMasterPlaylist, foreign), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the token of another video, got %d", rec.Code)
+	}
+
+	for _, name := range []string{
+		"../secret.txt",
+		"../" + vhs.DASHFilesDir + "/" + ffhelp.DASHManifest,
+		"360p/../../secret.txt",
+		"/" + ffhelp.HLSMasterPlaylist,
+		"..",
+		"",
+	} {
+		if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", name, ""), owner); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for %q, got %d", name, rec.Code)
+		}
+	}
+	if rec = serveTestResponse(t, h.ServeHLSHandler, streamRequest(video, "hls", "360p/../"+ffhelp.HLSMasterPlaylist, ""), owner); rec.Code != http.StatusOK {
+		t.Errorf("expected the path within the directory to be served, got %d", rec.Code)
+	}
+}
+
+func TestCleanStreamPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{ffhelp.HLSMasterPlaylist, ffhelp.HLSMasterPlaylist, true},
+		{"360p/seg00000.ts", "360p/seg00000.ts", true},
+		{"360p/./seg00000.ts", "360p/seg00000.ts", true},
+		{"360p/../" + ffhelp.HLSMasterPlaylist, ffhelp.HLSMasterPlaylist, true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../secret.txt", "", false},
+		{"360p/../../secret.txt", "", false},
+		{"360p/../..", "", false},
+		{"/etc/passwd", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := handlers.CleanStreamPath(tt.name)
+		if name != tt.expected || ok != tt.ok {
+			t.Errorf("%q: expected %q %v, got %q %v", tt.name, tt.expected, tt.ok, name, ok)
+		}
+	}
+}
+
+func TestServeDASH(t *testing.T) {
+	manifest := `<MPD><SegmentTemplate initialization="init-$RepresentationID$.m4s" media="chunk-$RepresentationID$-$Number%05d$.m4s"/></MPD>`
+	video, owner := newTestStreamVideo(t, map[string]string{
+		vhs.DASHFilesDir + "/" + ffhelp.DASHManifest:     manifest,
+		vhs.DASHFilesDir + "/chunk-0-00001.m4s":          "chunk",
+		vhs.HLSFilesDir + "/" + ffhelp.HLSMasterPlaylist: "#EXTM3U\n",
+	})
+	h := newTestHandlers()
+
+	rec := serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", ffhelp.DASHManifest, ""), owner)
+	if rec.Code != http.StatusOK || rec.Body.String() != manifest {
+		t.Fatalf("expected the manifest as is for the owner, got %d %q", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != handlers.DASHManifestContentType {
+		t.Errorf("expected %s, got %s", handlers.DASHManifestContentType, contentType)
+	}
+
+	if rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", ffhelp.DASHManifest, ""), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a guest, got %d", rec.Code)
+	}
+
+	token, err := vhs.NewStreamToken(owner, video.ID(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := url.QueryEscape(token)
+
+	rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", ffhelp.DASHManifest, token), nil)
+	expected := `<MPD><SegmentTemplate initialization="init-$RepresentationID$.m4s?token=` + escaped +
+		`" media="chunk-$RepresentationID$-$Number%05d$.m4s?token=` + escaped + `"/></MPD>`
+	if rec.Code != http.StatusOK || rec.Body.String() != expected {
+		t.Errorf("expected the segment templates with the token\n%q\ngot %d\n%q", expected, rec.Code, rec.Body.String())
+	}
+
+	rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", "chunk-0-00001.m4s", token), nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "chunk" {
+		t.Errorf("expected the segment for the stream token, got %d %q", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != handlers.DASHSegmentContentType {
+		t.Errorf("expected %s, got %s", handlers.DASHSegmentContentType, contentType)
+	}
+
+	expired, err := vhs.NewStreamToken(owner, video.ID(), -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", ffhelp.DASHManifest, expired), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the expired token, got %d", rec.Code)
+	}
+
+	other := newTestVideo(t)
+	other.SetUser(owner.Id)
+	if err = other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := vhs.NewStreamToken(owner, other.ID(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", ffhelp.DASHManifest, foreign), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the token of another video, got %d", rec.Code)
+	}
+
+	name := "../" + vhs.HLSFilesDir + "/" + ffhelp.HLSMasterPlaylist
+	if rec = serveTestResponse(t, h.ServeDASHHandler, streamRequest(video, "dash", name, ""), owner); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for %q, got %d", name, rec.Code)
+	}
+}
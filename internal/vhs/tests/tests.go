@@ -2,8 +2,10 @@ package tests
 
 import (
 	"os"
+	"vhs/internal/vhs"
 	"vhs/pkg/collections"
 
+	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/tests"
 
 	_ "vhs/migrations"
@@ -29,4 +31,7 @@ func init() {
 	}
 
 	Collections = collections.NewCollections(PocketBase)
+
+	vhs.PocketBase = &pocketbase.PocketBase{App: PocketBase}
+	vhs.Collections = Collections
 }
@@ -0,0 +1,325 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"vhs/internal/http/handlers/v1"
+	"vhs/internal/vhs"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+func newTestHandlers() *handlers.Handlers {
+	return handlers.New(vhs.NewAppMock(&vhs.AppBaseMock{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}))
+}
+
+type tusRequest struct {
+	method  string
+	videoId string
+	auth    *core.Record
+	header  map[string]string
+	body    []byte
+}
+
+// serve calls the handler with the request and returns the response status.
+func (r *tusRequest) serve(t *testing.T, handler func(*core.RequestEvent) error) (int, http.Header) {
+	req := httptest.NewRequest(r.method, handlers.TusBasePath+"/"+r.videoId, bytes.NewReader(r.body))
+	req.SetPathValue("videoId", r.videoId)
+	req.Header.Set("Tus-Resumable", handlers.TusVersion)
+	for key, value := range r.header {
+		req.Header.Set(key, value)
+	}
+
+	return serveTestRequest(t, handler, req, r.auth)
+}
+
+// serveTestRequest calls the handler with the request of the user
+// and returns the response status, either written or of the returned api error.
+func serveTestRequest(t *testing.T, handler func(*core.RequestEvent) error, req *http.Request, auth *core.Record) (int, http.Header) {
+	rec := serveTestResponse(t, handler, req, auth)
+
+	return rec.Code, rec.Header()
+}
+
+// serveTestResponse serves the request, the status of the returned api error is kept in the recorder.
+func serveTestResponse(t *testing.T, handler func(*core.RequestEvent) error, req *http.Request, auth *core.Record) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: PocketBase, Auth: auth}
+	e.Request = req
+	e.Response = rec
+
+	if err := handler(e); err != nil {
+		var apiErr *router.ApiError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("expected api error, got %v", err)
+		}
+		rec.Code = apiErr.Status
+	}
+
+	return rec
+}
+
+// newTestTusUpload creates an upload of the size and returns its id.
+func newTestTusUpload(t *testing.T, h *handlers.Handlers, user *core.Record, size int) string {
+	status, header := (&tusRequest{
+		method: http.MethodPost,
+		auth:   user,
+		header: map[string]string{
+			"Upload-Length":   strconv.Itoa(size),
+			"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("clip.mp4")) + ",is_draft",
+		},
+	}).serve(t, h.TusCreateHandler)
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+
+	videoId, ok := strings.CutPrefix(header.Get("Location"), handlers.TusBasePath+"/")
+	if !ok || videoId == "" {
+		t.Fatalf("expected the upload location, got %q", header.Get("Location"))
+	}
+	t.Cleanup(func() {
+		if video, err := vhs.NewVideoFromId(videoId); err == nil {
+			video.Delete()
+		}
+	})
+
+	return videoId
+}
+
+func TestTusCreate(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	h := newTestHandlers()
+	user := newTestUser(t)
+	videoId := newTestTusUpload(t, h, user, 2048)
+
+	session, err := vhs.NewUploadSessionFromVideoId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Name() != "clip.mp4" {
+		t.Errorf("expected the name from the metadata, got %q", session.Name())
+	}
+	if session.Size() != 2048 {
+		t.Errorf("expected size 2048, got %d", session.Size())
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"no length", map[string]string{}, http.StatusBadRequest},
+		{"bad length", map[string]string{"Upload-Length": "-1"}, http.StatusBadRequest},
+		{"too large", map[string]string{"Upload-Length": strconv.Itoa(vhs.MaxUploadSize + 1)}, http.StatusRequestEntityTooLarge},
+		{"bad metadata", map[string]string{"Upload-Length": "1", "Upload-Metadata": "filename !"}, http.StatusBadRequest},
+		{"empty metadata key", map[string]string{"Upload-Length": "1", "Upload-Metadata": " ,filename"}, http.StatusBadRequest},
+		{"bad version", map[string]string{"Upload-Length": "1", "Tus-Resumable": "0.2.2"}, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header := (&tusRequest{method: http.MethodPost, auth: user, header: tt.header}).serve(t, h.TusCreateHandler)
+			if status != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, status)
+			}
+			if header.Get("Tus-Resumable") != handlers.TusVersion {
+				t.Errorf("expected the Tus-Resumable header, got %q", header.Get("Tus-Resumable"))
+			}
+		})
+	}
+}
+
+func TestTusVersion(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	h := newTestHandlers()
+	user := newTestUser(t)
+	videoId := newTestTusUpload(t, h, user, 1024)
+
+	for _, handler := range []func(*core.RequestEvent) error{h.TusHeadHandler, h.TusPatchHandler, h.TusDeleteHandler} {
+		status, header := (&tusRequest{
+			method:  http.MethodHead,
+			videoId: videoId,
+			auth:    user,
+			header:  map[string]string{"Tus-Resumable": "0.2.2"},
+		}).serve(t, handler)
+		if status != http.StatusPreconditionFailed {
+			t.Errorf("expected 412, got %d", status)
+		}
+		if header.Get("Tus-Version") != handlers.TusVersion {
+			t.Errorf("expected the supported version, got %q", header.Get("Tus-Version"))
+		}
+	}
+
+	if _, err := vhs.NewUploadSessionFromVideoId(videoId); err != nil {
+		t.Errorf("expected the upload to be kept, got %v", err)
+	}
+}
+
+func TestTusPatch(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	ffmpeg := vhs.FFmpeg
+	defer func() { vhs.FFmpeg = ffmpeg }()
+	vhs.FFmpeg = newFakeRunner(t)
+
+	jobs := vhs.Jobs
+	defer func() { vhs.Jobs = jobs }()
+	enqueued := ""
+	vhs.Jobs = &testJobQueue{enqueue: func(videoId string, _ string) error {
+		enqueued = videoId
+		return nil
+	}}
+
+	h := newTestHandlers()
+	user := newTestUser(t)
+	p := readTestAsset(t, "assets/black_30m.mp4", 2048)
+	videoId := newTestTusUpload(t, h, user, len(p))
+
+	head := func(auth *core.Record) (int, http.Header) {
+		return (&tusRequest{method: http.MethodHead, videoId: videoId, auth: auth}).serve(t, h.TusHeadHandler)
+	}
+	patch := func(auth *core.Record, header map[string]string, body []byte) (int, http.Header) {
+		if _, ok := header["Content-Type"]; !ok {
+			header["Content-Type"] = "application/offset+octet-stream"
+		}
+		return (&tusRequest{method: http.MethodPatch, videoId: videoId, auth: auth, header: header, body: body}).serve(t, h.TusPatchHandler)
+	}
+
+	status, header := head(user)
+	if status != http.StatusOK || header.Get("Upload-Offset") != "0" || header.Get("Upload-Length") != "2048" {
+		t.Fatalf("expected 200 with offset 0 and length 2048, got %d, %q, %q", status, header.Get("Upload-Offset"), header.Get("Upload-Length"))
+	}
+
+	status, header = patch(user, map[string]string{"Upload-Offset": "0"}, p[:1024])
+	if status != http.StatusNoContent || header.Get("Upload-Offset") != "1024" {
+		t.Fatalf("expected 204 with offset 1024, got %d, %q", status, header.Get("Upload-Offset"))
+	}
+
+	tests := []struct {
+		name   string
+		auth   *core.Record
+		header map[string]string
+		status int
+	}{
+		{"offset mismatch", user, map[string]string{"Upload-Offset": "0"}, http.StatusConflict},
+		{"no offset", user, map[string]string{}, http.StatusBadRequest},
+		{"negative offset", user, map[string]string{"Upload-Offset": "-1"}, http.StatusBadRequest},
+		{"bad content type", user, map[string]string{"Upload-Offset": "1024", "Content-Type": "application/octet-stream"}, http.StatusUnsupportedMediaType},
+		{"bad checksum header", user, map[string]string{"Upload-Offset": "1024", "Upload-Checksum": "sha1"}, http.StatusBadRequest},
+		{"another user", newTestUser(t), map[string]string{"Upload-Offset": "1024"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := patch(tt.auth, tt.header, p[1024:])
+			if status != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, status)
+			}
+		})
+	}
+
+	// the rejected requests don't change the upload
+	if _, header = head(user); header.Get("Upload-Offset") != "1024" {
+		t.Fatalf("expected offset 1024, got %q", header.Get("Upload-Offset"))
+	}
+	if status, _ = head(newTestUser(t)); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", status)
+	}
+
+	status, header = patch(user, map[string]string{"Upload-Offset": "1024"}, p[1024:])
+	if status != http.StatusNoContent || header.Get("Upload-Offset") != "2048" {
+		t.Fatalf("expected 204 with offset 2048, got %d, %q", status, header.Get("Upload-Offset"))
+	}
+	if enqueued != videoId {
+		t.Errorf("expected the complete upload to be queued, got %q", enqueued)
+	}
+	if status, _ = head(user); status != http.StatusNotFound {
+		t.Errorf("expected the session of the complete upload to be removed, got %d", status)
+	}
+}
+
+func TestTusDelete(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	h := newTestHandlers()
+	user := newTestUser(t)
+	videoId := newTestTusUpload(t, h, user, 1024)
+
+	del := func(auth *core.Record) int {
+		status, _ := (&tusRequest{method: http.MethodDelete, videoId: videoId, auth: auth}).serve(t, h.TusDeleteHandler)
+		return status
+	}
+
+	if status := del(newTestUser(t)); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", status)
+	}
+	if _, err := vhs.NewUploadSessionFromVideoId(videoId); err != nil {
+		t.Fatalf("expected the upload to be kept, got %v", err)
+	}
+
+	if status := del(user); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if _, err := vhs.NewUploadSessionFromVideoId(videoId); err == nil {
+		t.Error("expected the session to be removed")
+	}
+	if _, err := vhs.NewVideoFromId(videoId); err == nil {
+		t.Error("expected the video to be removed")
+	}
+
+	if status := del(user); status != http.StatusNotFound {
+		t.Errorf("expected 404 for the terminated upload, got %d", status)
+	}
+}
+
+func TestTusChecksum(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	h := newTestHandlers()
+	user := newTestUser(t)
+	p := readTestAsset(t, "assets/black_30m.mp4", 2048)
+	videoId := newTestTusUpload(t, h, user, len(p))
+
+	patch := func(sum []byte) (int, http.Header) {
+		return (&tusRequest{
+			method:  http.MethodPatch,
+			videoId: videoId,
+			auth:    user,
+			header: map[string]string{
+				"Content-Type":    "application/offset+octet-stream",
+				"Upload-Offset":   "0",
+				"Upload-Checksum": "sha256 " + base64.StdEncoding.EncodeToString(sum),
+			},
+			body: p[:1024],
+		}).serve(t, h.TusPatchHandler)
+	}
+
+	wrong := sha256.Sum256(p[1:1024])
+	if status, _ := patch(wrong[:]); status != 460 {
+		t.Errorf("expected 460, got %d", status)
+	}
+
+	// the part which doesn't match its checksum is discarded
+	_, header := (&tusRequest{method: http.MethodHead, videoId: videoId, auth: user}).serve(t, h.TusHeadHandler)
+	if header.Get("Upload-Offset") != "0" {
+		t.Errorf("expected offset 0, got %q", header.Get("Upload-Offset"))
+	}
+
+	sum := sha256.Sum256(p[:1024])
+	if status, header := patch(sum[:]); status != http.StatusNoContent || header.Get("Upload-Offset") != "1024" {
+		t.Errorf("expected 204 with offset 1024, got %d, %q", status, header.Get("Upload-Offset"))
+	}
+}
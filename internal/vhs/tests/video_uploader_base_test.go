@@ -1,10 +1,17 @@
 package tests
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"vhs/internal/vhs"
 	"vhs/internal/vhs/entities"
 	"vhs/pkg/ffhelp"
@@ -12,19 +19,89 @@ import (
 	"github.com/pocketbase/pocketbase/core"
 )
 
-func TestCreateStoryBoard(t *testing.T) {
-	defer os.RemoveAll(vhs.UploadDir)
+func newFakeRunner(t *testing.T) *ffhelp.FakeRunner {
+	return newFakeRunnerFromProbe(t, "assets/black_30m.json")
+}
+
+func newFakeRunnerFromProbe(t *testing.T, probePath string) *ffhelp.FakeRunner {
+	probeJSON, err := os.ReadFile(probePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ffhelp.NewFakeRunner(probeJSON)
+}
+
+func newTestUser(t *testing.T) *core.Record {
+	users, err := Collections.Get("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := core.GenerateDefaultRandomId()
 
-	col, err := Collections.Get(entities.VideosCollection)
+	user := core.NewRecord(users)
+	user.SetEmail(name + "@example.com")
+	user.SetPassword("1234567890")
+	user.Set("name", name)
+	if err = PocketBase.Save(user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func newTestVideo(t *testing.T) vhs.Video {
+	user := newTestUser(t)
+
+	videos, err := Collections.Get(entities.VideosCollection)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	record := core.NewRecord(col)
-	videoMock := NewVideoBaseMockFromRecord(record)
-	videoMock.Id = "testId"
+	video := vhs.NewVideoFromRecord(core.NewRecord(videos))
+	video.SetName("test")
+	video.SetStatus(entities.StatusClosed)
+	video.SetUser(user.Id)
+	if err = video.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	return video
+}
+
+func newTestProcessor(t *testing.T, video vhs.Video, runner ffhelp.Runner) *vhs.VideoUploaderBase {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "video_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tmpFile.Close() })
 
-	ffhelp, err := ffhelp.Input("assets/black_30m.mp4")
+	// only the header is needed for the mime type of the video file
+	asset, err := os.Open("assets/black_30m.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer asset.Close()
+
+	if _, err = io.CopyN(tmpFile, asset, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	return vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+		TmpFile: tmpFile,
+		Runner:  runner,
+		Video:   video,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestCreateStoryBoard(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	videoMock := NewVideoBaseMockFromRecord(newTestVideo(t).ProxyRecord())
+
+	ffhelp, err := ffhelp.InputWithRunner("assets/black_30m.mp4", newFakeRunner(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,3 +120,907 @@ func TestCreateStoryBoard(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestProcessVideo(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	runner := newFakeRunner(t)
+	video := newTestVideo(t)
+
+	err := newTestProcessor(t, video, runner).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if video.ProcessingState() != entities.ProcessingStateReady || video.ProcessingProgress() != 100 {
+		t.Errorf("expected ready 100, got %s %v", video.ProcessingState(), video.ProcessingProgress())
+	}
+	if video.Duration() != 1800 {
+		t.Errorf("expected duration 1800, got %v", video.Duration())
+	}
+	// 360 thumbnails fit into a single sheet
+	if len(video.Thumbnails()) != 1 {
+		t.Errorf("expected 1 sprite sheet, got %d", len(video.Thumbnails()))
+	}
+	if video.WebVTT() == "" || video.Video() == "" || video.Preview() == "" {
+		t.Error("expected the video, webvtt and preview files to be set")
+	}
+	if video.TeaserMP4() == "" || video.TeaserWebP() == "" {
+		t.Error("expected the teaser files to be set")
+	}
+	if video.Waveform() != "" {
+		t.Error("expected no waveform for the video without audio")
+	}
+	if len(video.PreviewCandidates()) != vhs.PreviewCandidatesCount {
+		t.Errorf("expected %d preview candidates, got %d", vhs.PreviewCandidatesCount, len(video.PreviewCandidates()))
+	}
+	if video.PreviewIsSet() {
+		t.Error("expected the generated preview not to be set by the user")
+	}
+	if len(video.Renditions()) != len(ffhelp.DefaultRenditions) {
+		t.Errorf("expected %d renditions, got %d", len(ffhelp.DefaultRenditions), len(video.Renditions()))
+	}
+
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for _, key := range []string{
+		vhs.HLSFilesDir + "/" + ffhelp.HLSMasterPlaylist,
+		vhs.HLSFilesDir + "/360p/" + ffhelp.HLSMediaPlaylist,
+		vhs.DASHFilesDir + "/" + ffhelp.DASHManifest,
+	} {
+		exists, err := fs.Exists(video.BaseFilesPath() + "/" + key)
+		if err != nil || !exists {
+			t.Errorf("expected %s to be stored", key)
+		}
+	}
+
+	// thumbnails, preview candidates, MP4 and WebP teasers,
+	// then transcoding and HLS for each rendition and DASH
+	expected := 1 + vhs.PreviewCandidatesCount + 2 + 2*len(ffhelp.DefaultRenditions) + 1
+	if commands := runner.Commands(); len(commands) != expected {
+		t.Errorf("expected %d ffmpeg commands, got %d", expected, len(commands))
+	}
+}
+
+func TestProcessVerticalVideo(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	// 1920x1080 rotated by 90 degrees
+	runner := newFakeRunnerFromProbe(t, "assets/vertical.json")
+	video := newTestVideo(t)
+
+	err := newTestProcessor(t, video, runner).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := video.Renditions()[0]; r.Width != 1080 || r.Height != 1920 {
+		t.Errorf("expected the highest rendition to be 1080x1920, got %dx%d", r.Width, r.Height)
+	}
+
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	r, err := fs.GetReader(video.BaseFilesPath() + "/" + video.WebVTT())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	vtt, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sprites keep the aspect ratio of the video
+	if !strings.Contains(string(vtt), "#xywh=57,0,57,101") {
+		t.Errorf("expected the sprites of 57x101, got:\n%s", vtt)
+	}
+
+	r, err = fs.GetReader(video.BaseFilesPath() + "/" + video.Waveform())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var peaks ffhelp.Peaks
+	if err = json.NewDecoder(r).Decode(&peaks); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 peaks per second of the audio
+	if peaks.Length != 600 || len(peaks.Data) != 2*peaks.Length {
+		t.Errorf("expected 600 peaks, got %d with %d values", peaks.Length, len(peaks.Data))
+	}
+}
+
+func TestProcessAudio(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	// FLAC with a cover art and the duration only in the format
+	runner := newFakeRunnerFromProbe(t, "assets/audio.json")
+	video := newTestVideo(t)
+
+	err := newTestProcessor(t, video, runner).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if video.MediaKind() != entities.MediaKindAudio {
+		t.Errorf("expected media kind audio, got %q", video.MediaKind())
+	}
+	if video.ProcessingState() != entities.ProcessingStateReady {
+		t.Errorf("expected ready, got %s", video.ProcessingState())
+	}
+	if video.Duration() != 180 {
+		t.Errorf("expected duration 180, got %v", video.Duration())
+	}
+	if len(video.Thumbnails()) != 0 || video.WebVTT() != "" || video.TeaserMP4() != "" {
+		t.Error("expected no sprites and teasers for the audio")
+	}
+	if len(video.Renditions()) != 0 {
+		t.Errorf("expected no renditions, got %d", len(video.Renditions()))
+	}
+	if video.Preview() == "" || video.PreviewIsSet() {
+		t.Error("expected the generated preview to be set")
+	}
+	if video.Waveform() == "" {
+		t.Error("expected the waveform to be set")
+	}
+
+	// the cover art and the peaks
+	commands := runner.Commands()
+	if len(commands) != 2 {
+		t.Fatalf("expected 2 ffmpeg commands, got %d", len(commands))
+	}
+	if !slices.Contains(commands[0], "0:1") {
+		t.Errorf("expected the cover art to be the preview, got %v", commands[0])
+	}
+}
+
+func TestProcessVideoFFmpegError(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	runner := newFakeRunner(t)
+	runner.Fail = func(args []string) error {
+		if slices.Contains(args, "libx264") {
+			return &ffhelp.Error{
+				Command:  "ffmpeg",
+				Args:     args,
+				ExitCode: 1,
+				Stderr:   "Invalid data found when processing input",
+			}
+		}
+		return nil
+	}
+	video := newTestVideo(t)
+
+	err := newTestProcessor(t, video, runner).Process()
+
+	var ffErr *ffhelp.Error
+	if !errors.As(err, &ffErr) {
+		t.Fatalf("expected ffhelp.Error, got %v", err)
+	}
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.FFmpegError() == nil || video.FFmpegError().ExitCode != 1 {
+		t.Errorf("expected the ffmpeg error to be recorded on the video, got %+v", video.FFmpegError())
+	}
+
+	// the arguments and the output of ffmpeg aren't exposed by the API
+	public, err := json.Marshal(video.ProxyRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(public), "Invalid data") || strings.Contains(string(public), "libx264") {
+		t.Errorf("expected no ffmpeg details in the public record, got %s", public)
+	}
+	if video.ProcessingState() == entities.ProcessingStateReady {
+		t.Error("expected the video not to be ready")
+	}
+}
+
+// readTestAsset returns the first n bytes of the asset, which is enough for its mime type.
+func readTestAsset(t *testing.T, path string, n int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := make([]byte, n)
+	if _, err = io.ReadFull(f, p); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func newTestUploader(runner ffhelp.Runner) *vhs.VideoUploaderBase {
+	return vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+		Runner: runner,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func newTestUpload(t *testing.T, runner ffhelp.Runner, size int) (*vhs.VideoUploaderBase, string) {
+	uploader := newTestUploader(runner)
+
+	videoId, err := uploader.Start(&vhs.VideoUploadData{
+		Size:   size,
+		Name:   "test.mp4",
+		UserId: newTestUser(t).Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { uploader.Cancel() })
+
+	return uploader, videoId
+}
+
+func TestUploadRejectsUnsupportedMedia(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	allowlist := vhs.Config.Allowlist
+	defer func() { vhs.Config.Allowlist = allowlist }()
+	vhs.Config.Allowlist.VideoCodecs = []string{"vp9"}
+
+	runner := newFakeRunner(t)
+	uploader, videoId := newTestUpload(t, runner, 2*vhs.Config.ProbeHeadSize)
+
+	// the file is probed as soon as its head is received
+	_, err := uploader.UploadPart(make([]byte, vhs.Config.ProbeHeadSize))
+	if vhs.UploadErrorCode(err) != vhs.UploadErrorCodeUnsupported {
+		t.Fatalf("expected %s error, got %v", vhs.UploadErrorCodeUnsupported, err)
+	}
+	if !errors.Is(err, ffhelp.ErrUnsupportedMedia) {
+		t.Errorf("expected unsupported media error, got %v", err)
+	}
+	if len(runner.Probes()) != 1 {
+		t.Errorf("expected 1 probe, got %d", len(runner.Probes()))
+	}
+
+	if _, err = uploader.UploadPart([]byte{0}); !errors.Is(err, vhs.ErrUploadNotStarted) {
+		t.Errorf("expected the rejected upload to be stopped, got %v", err)
+	}
+
+	video, err := vhs.NewVideoFromId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.ProcessingState() != entities.ProcessingStateFailed {
+		t.Errorf("expected failed, got %s", video.ProcessingState())
+	}
+	if !strings.Contains(video.ProcessingError(), "h264") {
+		t.Errorf("expected the rejected codec in the error, got %q", video.ProcessingError())
+	}
+	if _, err = vhs.NewUploadSessionFromVideoId(videoId); err == nil {
+		t.Error("expected the upload session to be removed")
+	}
+}
+
+func TestUploadMimeType(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	head := readTestAsset(t, "assets/black_30m.mp4", 1024)
+
+	tests := []struct {
+		name  string
+		brand string
+		ok    bool
+	}{
+		{name: "mp4", brand: "isom", ok: true},
+		{name: "mov", brand: "qt  ", ok: true},
+		// ffprobe reports the same container as for mp4, but the file type isn't accepted by the video field
+		{name: "3gp", brand: "3gp4", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := slices.Clone(head)
+			copy(p[8:12], tt.brand)
+
+			uploader, videoId := newTestUpload(t, newFakeRunner(t), len(p))
+			if _, err := uploader.UploadPart(p); err != nil {
+				t.Fatal(err)
+			}
+
+			err := uploader.Finish(nil)
+			if tt.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ffhelp.ErrUnsupportedMedia) {
+				t.Fatalf("expected unsupported media error, got %v", err)
+			}
+			video, err := vhs.NewVideoFromId(videoId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(video.ProcessingError(), "video/3gpp") {
+				t.Errorf("expected the rejected file type in the error, got %q", video.ProcessingError())
+			}
+		})
+	}
+}
+
+func TestUploadValidatesOnFinish(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	runner := newFakeRunner(t)
+	uploader, _ := newTestUpload(t, runner, 1024)
+
+	// the file is smaller than the head, so it is probed only once it's complete
+	if _, err := uploader.UploadPart(readTestAsset(t, "assets/black_30m.mp4", 1024)); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.Probes()) != 0 {
+		t.Errorf("expected no probes before finish, got %d", len(runner.Probes()))
+	}
+
+	if err := uploader.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.Probes()) != 1 {
+		t.Errorf("expected 1 probe, got %d", len(runner.Probes()))
+	}
+}
+
+func TestUploadQuota(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	user := vhs.NewUserFromRecord(newTestUser(t))
+	user.SetQuotaBytes(1000)
+	user.SetQuotaVideos(2)
+	if err := user.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := func(size int) error {
+		uploader := vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		})
+		_, err := uploader.Start(&vhs.VideoUploadData{Size: size, Name: "test.mp4", UserId: user.ID()})
+		if err == nil {
+			t.Cleanup(func() { uploader.Cancel() })
+		}
+		return err
+	}
+
+	if err := start(1001); !errors.Is(err, vhs.ErrUploadQuota) {
+		t.Errorf("expected the upload larger than the quota to be rejected, got %v", err)
+	}
+	if err := start(600); err != nil {
+		t.Fatal(err)
+	}
+	// the unfinished upload takes its whole size
+	if err := start(600); !errors.Is(err, vhs.ErrUploadQuota) {
+		t.Errorf("expected the upload over the quota to be rejected, got %v", err)
+	}
+	if err := start(400); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := user.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Bytes != 1000 || usage.Videos != 2 || usage.QuotaBytes != 1000 || usage.QuotaVideos != 2 {
+		t.Errorf("expected 1000 of 1000 bytes and 2 of 2 videos, got %+v", usage)
+	}
+
+	user.SetQuotaBytes(0)
+	if err = user.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err = start(1); !errors.Is(err, vhs.ErrUploadQuota) {
+		t.Errorf("expected the upload over the videos quota to be rejected, got %v", err)
+	}
+}
+
+func TestUploadQuotaConcurrentStarts(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	user := vhs.NewUserFromRecord(newTestUser(t))
+	user.SetQuotaBytes(1000)
+	if err := user.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the slow save of the videos lets all the uploads check the quota before any of them is counted
+	hookId := PocketBase.OnRecordCreate(entities.VideosCollection).BindFunc(func(e *core.RecordEvent) error {
+		time.Sleep(50 * time.Millisecond)
+		return e.Next()
+	})
+	defer PocketBase.OnRecordCreate(entities.VideosCollection).Unbind(hookId)
+
+	const uploads = 8
+	var wg sync.WaitGroup
+	errs := make([]error, uploads)
+	for i := range uploads {
+		uploader := vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		})
+		t.Cleanup(func() { uploader.Cancel() })
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = uploader.Start(&vhs.VideoUploadData{Size: 600, Name: "test.mp4", UserId: user.ID()})
+		}()
+	}
+	wg.Wait()
+
+	started := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			started++
+		case !errors.Is(err, vhs.ErrUploadQuota):
+			t.Errorf("expected quota error, got %v", err)
+		}
+	}
+	if started != 1 {
+		t.Errorf("expected only one upload to fit into the quota, got %d", started)
+	}
+
+	usage, err := user.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Bytes != 600 {
+		t.Errorf("expected 600 bytes to be used, got %d", usage.Bytes)
+	}
+}
+
+func TestUploadLimitPerUser(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	limit := vhs.Config.MaxUploadsPerUser
+	defer func() { vhs.Config.MaxUploadsPerUser = limit }()
+	vhs.Config.MaxUploadsPerUser = 2
+
+	user := newTestUser(t)
+	start := func() (*vhs.VideoUploaderBase, error) {
+		uploader := vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		})
+		_, err := uploader.Start(&vhs.VideoUploadData{Size: 1, Name: "test.mp4", UserId: user.Id})
+		if err == nil {
+			t.Cleanup(func() { uploader.Cancel() })
+		}
+		return uploader, err
+	}
+
+	first, err := start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = start(); !errors.Is(err, vhs.ErrUploadLimit) {
+		t.Fatalf("expected the third upload to be rejected, got %v", err)
+	}
+
+	// another user isn't limited by the uploads of the first one
+	other := vhs.NewVideoUploaderMock(&vhs.VideoUploaderBaseMock{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if _, err = other.Start(&vhs.VideoUploadData{Size: 1, Name: "test.mp4", UserId: newTestUser(t).Id}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.Cancel() })
+
+	// the suspended upload frees its place
+	if err = first.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = start(); err != nil {
+		t.Errorf("expected the upload to start after another one is suspended, got %v", err)
+	}
+}
+
+func TestLimitedRunner(t *testing.T) {
+	var running, maxRunning atomic.Int32
+
+	fake := newFakeRunner(t)
+	fake.Fail = func(args []string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		return nil
+	}
+
+	runner := ffhelp.NewLimitedRunner(fake, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runner.Probe("test.mp4"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning.Load() != 2 {
+		t.Errorf("expected at most 2 commands at once, got %d", maxRunning.Load())
+	}
+	if len(fake.Probes()) != 8 {
+		t.Errorf("expected all 8 commands to run, got %d", len(fake.Probes()))
+	}
+}
+
+func TestUploadHooksCount(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	upload := func() {
+		uploader, _ := newTestUpload(t, newFakeRunner(t), 1)
+		if err := uploader.Suspend(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upload()
+	hooks := PocketBase.OnRecordAfterUpdateSuccess().Length()
+
+	for i := 0; i < 5; i++ {
+		upload()
+	}
+
+	if n := PocketBase.OnRecordAfterUpdateSuccess().Length(); n != hooks {
+		t.Errorf("expected %d hooks after the uploads, got %d", hooks, n)
+	}
+}
+
+func TestUploadKeepsUserChanges(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	uploader, videoId := newTestUpload(t, newFakeRunner(t), 1024)
+	if _, err := uploader.UploadPart(readTestAsset(t, "assets/black_30m.mp4", 1024)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the user renames the video while it is uploaded
+	video, err := vhs.NewVideoFromId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video.SetName("renamed")
+	if err = video.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the uploader saves its own copy of the video
+	if err = uploader.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.Name() != "renamed" {
+		t.Errorf("expected the name changed by the user to be kept, got %q", video.Name())
+	}
+}
+
+type testJobQueue struct {
+	enqueue func(videoId string, file string) error
+}
+
+func (q *testJobQueue) Start() error {
+	return nil
+}
+
+func (q *testJobQueue) Enqueue(videoId string, file string) error {
+	return q.enqueue(videoId, file)
+}
+
+// blockingRunner blocks the first probe until the test resumes it.
+type blockingRunner struct {
+	ffhelp.Runner
+	once    sync.Once
+	probing chan struct{}
+	resume  chan struct{}
+}
+
+func (r *blockingRunner) Probe(filename string) ([]byte, error) {
+	r.once.Do(func() {
+		close(r.probing)
+		<-r.resume
+	})
+
+	return r.Runner.Probe(filename)
+}
+
+func TestUploadKeepsUserChangesWhileProcessing(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	jobs := vhs.Jobs
+	defer func() { vhs.Jobs = jobs }()
+
+	runner := &blockingRunner{
+		Runner:  newFakeRunner(t),
+		probing: make(chan struct{}),
+		resume:  make(chan struct{}),
+	}
+	processed := make(chan error, 1)
+
+	// the worker picks up the job before the upload is released
+	vhs.Jobs = &testJobQueue{enqueue: func(videoId string, _ string) error {
+		video, err := vhs.NewVideoFromId(videoId)
+		if err != nil {
+			return err
+		}
+
+		processor := newTestProcessor(t, video, runner)
+		go func() { processed <- processor.Process() }()
+		<-runner.probing
+
+		return nil
+	}}
+
+	uploader, videoId := newTestUpload(t, newFakeRunner(t), 1024)
+	if _, err := uploader.UploadPart(readTestAsset(t, "assets/black_30m.mp4", 1024)); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploader.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+	uploader.Done()
+
+	// the user renames the video while it is processed
+	video, err := vhs.NewVideoFromId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video.SetName("renamed")
+	if err = video.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	close(runner.resume)
+	if err = <-processed; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = video.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if video.Name() != "renamed" {
+		t.Errorf("expected the name changed by the user to be kept, got %q", video.Name())
+	}
+	if video.ProcessingState() != entities.ProcessingStateReady {
+		t.Errorf("expected the video to be ready, got %s", video.ProcessingState())
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	p := readTestAsset(t, "assets/black_30m.mp4", 2048)
+	runner := newFakeRunner(t)
+
+	uploader, videoId := newTestUpload(t, runner, len(p))
+	if _, err := uploader.UploadPart(p[:1024]); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploader.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := vhs.NewUploadSessionFromVideoId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the upload is continued by another connection
+	resumed := newTestUploader(runner)
+	t.Cleanup(func() { resumed.Cancel() })
+
+	id, err := resumed.Resume(&vhs.VideoUploadData{VideoId: videoId, UserId: session.User()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != videoId {
+		t.Errorf("expected video %s, got %s", videoId, id)
+	}
+	if resumed.Offset() != 1024 {
+		t.Errorf("expected the upload to resume from 1024, got %d", resumed.Offset())
+	}
+
+	done, err := resumed.UploadPart(p[1024:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Error("expected the upload to be complete")
+	}
+	if err = resumed.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := os.ReadFile(session.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != string(p) {
+		t.Error("expected the resumed file to match the uploaded bytes")
+	}
+}
+
+func TestUploadOfAnotherUser(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	uploader, videoId := newTestUpload(t, newFakeRunner(t), 1024)
+	if err := uploader.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := vhs.NewUploadSessionFromVideoId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newTestUser(t)
+
+	_, err = newTestUploader(newFakeRunner(t)).Resume(&vhs.VideoUploadData{VideoId: videoId, UserId: other.Id})
+	if !errors.Is(err, vhs.ErrUploadAccess) {
+		t.Fatalf("expected access error, got %v", err)
+	}
+	if strings.Contains(err.Error(), session.User()) {
+		t.Errorf("expected the owner not to be disclosed, got %q", err)
+	}
+
+	app := vhs.NewAppMock(&vhs.AppBaseMock{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if _, err = app.UploadStatus(videoId, other.Id); !errors.Is(err, vhs.ErrUploadAccess) {
+		t.Errorf("expected access error for the status, got %v", err)
+	}
+
+	// the missing upload isn't told apart from the upload of another user
+	_, err = newTestUploader(newFakeRunner(t)).Resume(&vhs.VideoUploadData{VideoId: "missing", UserId: other.Id})
+	if !errors.Is(err, vhs.ErrUploadAccess) {
+		t.Errorf("expected access error for the missing upload, got %v", err)
+	}
+
+	// the owner still can resume
+	status, err := app.UploadStatus(videoId, session.User())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Size != 1024 || status.Offset != 0 {
+		t.Errorf("expected size 1024 and offset 0, got %d and %d", status.Size, status.Offset)
+	}
+}
+
+func TestCleanupUploadSessions(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	runner := newFakeRunner(t)
+	expired := time.Now().Add(-vhs.UploadSessionTTL - time.Hour)
+
+	newSession := func(suspend bool, modTime time.Time) vhs.UploadSession {
+		uploader, videoId := newTestUpload(t, runner, 1024)
+		if suspend {
+			if err := uploader.Suspend(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		session, err := vhs.NewUploadSessionFromVideoId(videoId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chtimes(session.Path(), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		return session
+	}
+
+	stale := newSession(true, expired)
+	recent := newSession(true, time.Now())
+	// the upload which is being written isn't removed, even if it's idle
+	active := newSession(false, expired)
+
+	vhs.NewAppMock(&vhs.AppBaseMock{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}).CleanupUploadSessions()
+
+	if _, err := vhs.NewUploadSessionFromVideoId(stale.Video()); err == nil {
+		t.Error("expected the expired session to be removed")
+	}
+	if _, err := vhs.NewVideoFromId(stale.Video()); err == nil {
+		t.Error("expected the video of the expired session to be removed")
+	}
+	if fileExists(stale.Path()) {
+		t.Error("expected the file of the expired session to be removed")
+	}
+
+	for _, session := range []vhs.UploadSession{recent, active} {
+		if _, err := vhs.NewUploadSessionFromVideoId(session.Video()); err != nil {
+			t.Errorf("expected the session of %s to be kept, got %v", session.Video(), err)
+		}
+		if !fileExists(session.Path()) {
+			t.Errorf("expected the file of %s to be kept", session.Video())
+		}
+	}
+}
+
+func TestUploadDoneEnqueueError(t *testing.T) {
+	defer os.RemoveAll(vhs.UploadDir)
+
+	jobs := vhs.Jobs
+	defer func() { vhs.Jobs = jobs }()
+	vhs.Jobs = &testJobQueue{enqueue: func(string, string) error {
+		return errors.New("queue is unavailable")
+	}}
+
+	p := readTestAsset(t, "assets/black_30m.mp4", 1024)
+	uploader, videoId := newTestUpload(t, newFakeRunner(t), len(p))
+	if _, err := uploader.UploadPart(p); err != nil {
+		t.Fatal(err)
+	}
+	session, err := vhs.NewUploadSessionFromVideoId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader.Done()
+
+	video, err := vhs.NewVideoFromId(videoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.ProcessingState() != entities.ProcessingStateFailed {
+		t.Errorf("expected the video to fail, got %s", video.ProcessingState())
+	}
+	if !strings.Contains(video.ProcessingError(), "queue is unavailable") {
+		t.Errorf("expected the queue error on the video, got %q", video.ProcessingError())
+	}
+	// the file is removed with the session by the cleanup
+	if _, err = vhs.NewUploadSessionFromVideoId(videoId); err != nil {
+		t.Errorf("expected the session to be kept, got %v", err)
+	}
+	if !fileExists(session.Path()) {
+		t.Error("expected the uploaded file to be kept")
+	}
+
+	// the queued upload has no session left
+	vhs.Jobs = &testJobQueue{enqueue: func(string, string) error { return nil }}
+	uploader, videoId = newTestUpload(t, newFakeRunner(t), len(p))
+	if _, err = uploader.UploadPart(p); err != nil {
+		t.Fatal(err)
+	}
+
+	uploader.Done()
+
+	if video, err = vhs.NewVideoFromId(videoId); err != nil {
+		t.Fatal(err)
+	}
+	if video.ProcessingState() != entities.ProcessingStateQueued {
+		t.Errorf("expected the video to be queued, got %s", video.ProcessingState())
+	}
+	if _, err = vhs.NewUploadSessionFromVideoId(videoId); err == nil {
+		t.Error("expected the session to be removed")
+	}
+}
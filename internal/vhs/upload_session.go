@@ -0,0 +1,22 @@
+package vhs
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type UploadSession interface {
+	core.RecordProxy
+	Save() error
+	Delete() error
+	ID() string
+	Video() string
+	SetVideo(string)
+	User() string
+	SetUser(string)
+	Name() string
+	SetName(string)
+	Size() int
+	SetSize(int)
+	Path() string
+	SetPath(string)
+}
@@ -0,0 +1,101 @@
+package vhs
+
+import (
+	"database/sql"
+	"errors"
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type UploadSessionBase struct {
+	core.BaseRecordProxy
+}
+
+func NewUploadSession() (UploadSession, error) {
+	col, err := Collections.Get(entities.UploadSessionsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUploadSessionFromRecord(core.NewRecord(col)), nil
+}
+
+func NewUploadSessionFromRecord(record *core.Record) UploadSession {
+	s := &UploadSessionBase{}
+	s.SetProxyRecord(record)
+
+	return s
+}
+
+func NewUploadSessionFromVideoId(videoId string) (UploadSession, error) {
+	record, err := PocketBase.FindFirstRecordByData(entities.UploadSessionsCollection, "video", videoId)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUploadSessionFromRecord(record), nil
+}
+
+// NewUploadSessionOfUser returns ErrUploadAccess if the session doesn't exist or belongs to another user,
+// so the other users can't tell them apart.
+func NewUploadSessionOfUser(videoId string, userId string) (UploadSession, error) {
+	session, err := NewUploadSessionFromVideoId(videoId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && session.User() != userId) {
+		return nil, ErrUploadAccess
+	}
+
+	return session, err
+}
+
+func (s *UploadSessionBase) Save() error {
+	return PocketBase.Save(s)
+}
+
+func (s *UploadSessionBase) Delete() error {
+	return PocketBase.Delete(s)
+}
+
+func (s *UploadSessionBase) ID() string {
+	return s.Id
+}
+
+func (s *UploadSessionBase) Video() string {
+	return s.GetString("video")
+}
+
+func (s *UploadSessionBase) SetVideo(id string) {
+	s.Set("video", id)
+}
+
+func (s *UploadSessionBase) User() string {
+	return s.GetString("user")
+}
+
+func (s *UploadSessionBase) SetUser(id string) {
+	s.Set("user", id)
+}
+
+func (s *UploadSessionBase) Name() string {
+	return s.GetString("name")
+}
+
+func (s *UploadSessionBase) SetName(name string) {
+	s.Set("name", name)
+}
+
+func (s *UploadSessionBase) Size() int {
+	return s.GetInt("size")
+}
+
+func (s *UploadSessionBase) SetSize(size int) {
+	s.Set("size", size)
+}
+
+func (s *UploadSessionBase) Path() string {
+	return s.GetString("path")
+}
+
+func (s *UploadSessionBase) SetPath(path string) {
+	s.Set("path", path)
+}
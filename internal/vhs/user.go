@@ -0,0 +1,18 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type User interface {
+	core.RecordProxy
+	Save() error
+	ID() string
+	QuotaBytes() int
+	SetQuotaBytes(int)
+	QuotaVideos() int
+	SetQuotaVideos(int)
+	Usage() (*entities.UserUsage, error)
+}
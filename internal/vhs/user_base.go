@@ -0,0 +1,83 @@
+package vhs
+
+import (
+	"vhs/internal/vhs/entities"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type UserBase struct {
+	core.BaseRecordProxy
+}
+
+func NewUserFromRecord(record *core.Record) User {
+	u := &UserBase{}
+	u.SetProxyRecord(record)
+
+	return u
+}
+
+func NewUserFromId(id string) (User, error) {
+	record, err := PocketBase.FindRecordById(entities.UsersCollection, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUserFromRecord(record), nil
+}
+
+func (u *UserBase) Save() error {
+	return PocketBase.Save(u)
+}
+
+func (u *UserBase) ID() string {
+	return u.Id
+}
+
+// QuotaBytes returns the storage quota of the user, Config.QuotaBytes if it isn't set.
+// Zero means no quota.
+func (u *UserBase) QuotaBytes() int {
+	if quota := u.GetInt("quota_bytes"); quota > 0 {
+		return quota
+	}
+
+	return Config.QuotaBytes
+}
+
+func (u *UserBase) SetQuotaBytes(quota int) {
+	u.Set("quota_bytes", quota)
+}
+
+// QuotaVideos returns the max number of the videos of the user, Config.QuotaVideos if it isn't set.
+// Zero means no quota.
+func (u *UserBase) QuotaVideos() int {
+	if quota := u.GetInt("quota_videos"); quota > 0 {
+		return quota
+	}
+
+	return Config.QuotaVideos
+}
+
+func (u *UserBase) SetQuotaVideos(quota int) {
+	u.Set("quota_videos", quota)
+}
+
+// Usage counts the videos of the user and their sizes.
+// The unfinished uploads take the whole size they were started with.
+func (u *UserBase) Usage() (*entities.UserUsage, error) {
+	usage := &entities.UserUsage{}
+	err := PocketBase.DB().
+		Select("COALESCE(SUM(size), 0) AS bytes", "COUNT(*) AS videos").
+		From(entities.VideosCollection).
+		Where(dbx.HashExp{"user": u.Id}).
+		One(usage)
+	if err != nil {
+		return nil, err
+	}
+
+	usage.QuotaBytes = u.QuotaBytes()
+	usage.QuotaVideos = u.QuotaVideos()
+
+	return usage, nil
+}
@@ -1,6 +1,7 @@
 package vhs
 
 import (
+	"errors"
 	"vhs/internal/vhs/entities"
 	"vhs/pkg/ffhelp"
 
@@ -11,6 +12,7 @@ import (
 type Video interface {
 	core.RecordProxy
 	Save() error
+	Delete() error
 	Refresh() error
 	ID() string
 	Name() string
@@ -19,15 +21,35 @@ type Video interface {
 	SetDescription(string)
 	Preview() string
 	SetPreview(*filesystem.File)
+	SetGeneratedPreview(*filesystem.File)
+	PreviewCandidates() []*entities.PreviewCandidate
+	SetPreviewCandidates([]*entities.PreviewCandidate, []*filesystem.File)
 	Thumbnails() []string
+	TeaserWebP() string
+	SetTeaserWebP(*filesystem.File)
+	TeaserMP4() string
+	SetTeaserMP4(*filesystem.File)
+	Waveform() string
+	SetWaveform(*filesystem.File)
 	SetThumbnails([]*filesystem.File)
 	Video() string
 	SetVideo(*filesystem.File)
 	SetVideoPath(string)
 	Status() entities.Status
 	SetStatus(entities.Status)
+	MediaKind() entities.MediaKind
+	SetMediaKind(entities.MediaKind)
+	ProcessingState() entities.ProcessingState
+	ProcessingProgress() float64
+	SetProcessingState(entities.ProcessingState, float64)
+	ProcessingError() string
+	SetProcessingError(string)
+	FFmpegError() *ffhelp.Error
+	SetFFmpegError(*ffhelp.Error)
 	User() string
 	SetUser(string)
+	Sha256() string
+	SetSha256(string)
 	WebVTT() string
 	SetWebVTT(*filesystem.File)
 	Chapters() *[]*entities.VideoChapter
@@ -36,6 +58,18 @@ type Video interface {
 	SetMeta(*ffhelp.Probe)
 	Duration() float64
 	SetDuration(float64)
+	Size() int
+	SetSize(int)
+	Renditions() []ffhelp.Rendition
+	SetRenditions([]ffhelp.Rendition)
 	BaseFilesPath() string
 	PreviewIsSet() bool
 }
+
+var (
+	ErrVideoAccess       = errors.New("video belongs to another user")
+	ErrVideoNotProcessed = errors.New("video is not processed yet")
+	ErrPreviewTime       = errors.New("preview time is out of the video")
+	ErrPreviewCandidate  = errors.New("preview candidate not found")
+	ErrPreviewAudio      = errors.New("audio has no frames to take the preview from")
+)
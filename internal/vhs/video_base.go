@@ -43,6 +43,10 @@ func (v *VideoBase) Save() error {
 	return PocketBase.Save(v)
 }
 
+func (v *VideoBase) Delete() error {
+	return PocketBase.Delete(v)
+}
+
 func (v *VideoBase) Refresh() error {
 	record, err := PocketBase.FindRecordById(entities.VideosCollection, v.Id)
 	if err != nil {
@@ -106,6 +110,35 @@ func (v *VideoBase) SetPreview(file *filesystem.File) {
 	v.Set("preview_is_set", true)
 }
 
+// SetGeneratedPreview sets the preview made by the app, which can be replaced
+// on the video processing, unlike the one set by the user.
+func (v *VideoBase) SetGeneratedPreview(file *filesystem.File) {
+	v.Set("preview", file)
+	v.Set("preview_is_set", false)
+}
+
+func (v *VideoBase) PreviewCandidates() []*entities.PreviewCandidate {
+	files := v.GetStringSlice("preview_candidates")
+
+	var candidates []*entities.PreviewCandidate
+	for i, c := range v.info.PreviewCandidates {
+		if i >= len(files) {
+			break
+		}
+
+		candidate := *c
+		candidate.File = files[i]
+		candidates = append(candidates, &candidate)
+	}
+
+	return candidates
+}
+
+func (v *VideoBase) SetPreviewCandidates(candidates []*entities.PreviewCandidate, files []*filesystem.File) {
+	v.info.PreviewCandidates = candidates
+	v.Set("preview_candidates", files)
+}
+
 func (v *VideoBase) Thumbnails() []string {
 	return v.GetStringSlice("thumbnails")
 }
@@ -114,6 +147,36 @@ func (v *VideoBase) SetThumbnails(files []*filesystem.File) {
 	v.Set("thumbnails", files)
 }
 
+func (v *VideoBase) TeaserWebP() string {
+	return v.GetString("teaser_webp")
+}
+
+func (v *VideoBase) SetTeaserWebP(file *filesystem.File) {
+	v.Set("teaser_webp", file)
+}
+
+func (v *VideoBase) TeaserMP4() string {
+	return v.GetString("teaser_mp4")
+}
+
+func (v *VideoBase) SetTeaserMP4(file *filesystem.File) {
+	v.Set("teaser_mp4", file)
+}
+
+func (v *VideoBase) Waveform() string {
+	return v.GetString("waveform")
+}
+
+// SetWaveform sets the peaks file of the audio, nil removes the waveform.
+func (v *VideoBase) SetWaveform(file *filesystem.File) {
+	if file == nil {
+		v.Set("waveform", "")
+		return
+	}
+
+	v.Set("waveform", file)
+}
+
 func (v *VideoBase) Video() string {
 	return v.GetString("video")
 }
@@ -134,6 +197,56 @@ func (v *VideoBase) SetStatus(status entities.Status) {
 	v.Set("status", string(status))
 }
 
+// Size returns the size of the uploaded file, which is counted in the quota of the user.
+func (v *VideoBase) Size() int {
+	return v.GetInt("size")
+}
+
+func (v *VideoBase) SetSize(size int) {
+	v.Set("size", size)
+}
+
+func (v *VideoBase) MediaKind() entities.MediaKind {
+	return entities.MediaKind(v.GetString("media_kind"))
+}
+
+func (v *VideoBase) SetMediaKind(kind entities.MediaKind) {
+	v.Set("media_kind", string(kind))
+}
+
+func (v *VideoBase) ProcessingState() entities.ProcessingState {
+	return entities.ProcessingState(v.GetString("processing_state"))
+}
+
+func (v *VideoBase) ProcessingProgress() float64 {
+	return v.GetFloat("processing_progress")
+}
+
+func (v *VideoBase) SetProcessingState(state entities.ProcessingState, progress float64) {
+	v.Set("processing_state", string(state))
+	v.Set("processing_progress", progress)
+}
+
+func (v *VideoBase) ProcessingError() string {
+	return v.GetString("processing_error")
+}
+
+func (v *VideoBase) SetProcessingError(s string) {
+	v.Set("processing_error", s)
+}
+
+// FFmpegError is the last ffmpeg failure while processing the video,
+// it is kept in the hidden "ffmpeg_error" field.
+func (v *VideoBase) FFmpegError() *ffhelp.Error {
+	var ffErr *ffhelp.Error
+	v.UnmarshalJSONField("ffmpeg_error", &ffErr)
+	return ffErr
+}
+
+func (v *VideoBase) SetFFmpegError(err *ffhelp.Error) {
+	v.Set("ffmpeg_error", err)
+}
+
 func (v *VideoBase) User() string {
 	return v.GetString("user")
 }
@@ -142,6 +255,14 @@ func (v *VideoBase) SetUser(user string) {
 	v.Set("user", user)
 }
 
+func (v *VideoBase) Sha256() string {
+	return v.GetString("sha256")
+}
+
+func (v *VideoBase) SetSha256(hash string) {
+	v.Set("sha256", hash)
+}
+
 func (v *VideoBase) WebVTT() string {
 	return v.GetString("webvtt")
 }
@@ -174,6 +295,14 @@ func (v *VideoBase) SetDuration(duration float64) {
 	v.info.Duration = duration
 }
 
+func (v *VideoBase) Renditions() []ffhelp.Rendition {
+	return v.info.Renditions
+}
+
+func (v *VideoBase) SetRenditions(renditions []ffhelp.Rendition) {
+	v.info.Renditions = renditions
+}
+
 func (v *VideoBase) BaseFilesPath() string {
 	return v.BaseRecordProxy.BaseFilesPath()
 }
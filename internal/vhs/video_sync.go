@@ -0,0 +1,64 @@
+package vhs
+
+import (
+	"sync"
+	"vhs/internal/vhs/entities"
+	"vhs/internal/vhs/helper"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// syncVideosHookId is the id of the single hook, which updates all the synced videos.
+const syncVideosHookId = "vhsSyncVideos"
+
+// syncedVideos holds the videos of the uploads and the processing keyed by their ids.
+// The changes made by the user in the meantime are copied to them,
+// so they aren't overwritten when the uploader saves the video.
+var syncedVideos = &videoRegistry{
+	videos: make(map[string]Video),
+}
+
+type videoRegistry struct {
+	mu     sync.RWMutex
+	videos map[string]Video
+}
+
+// add starts syncing the video until it is removed.
+// The hook is bound by its id, so it is never bound twice.
+func (r *videoRegistry) add(video Video) {
+	r.mu.Lock()
+	r.videos[video.ID()] = video
+	r.mu.Unlock()
+
+	PocketBase.OnRecordAfterUpdateSuccess(entities.VideosCollection).Bind(&hook.Handler[*core.RecordEvent]{
+		Id:   syncVideosHookId,
+		Func: r.sync,
+	})
+}
+
+// remove stops syncing the video, if it is still the registered one.
+// The upload and the processing of the same video overlap while the job is queued,
+// so the upload must not remove the video registered by the worker.
+func (r *videoRegistry) remove(video Video) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.videos[video.ID()] == video {
+		delete(r.videos, video.ID())
+	}
+}
+
+func (r *videoRegistry) sync(e *core.RecordEvent) error {
+	r.mu.RLock()
+	video, ok := r.videos[e.Record.Id]
+	r.mu.RUnlock()
+
+	if ok && video.ProxyRecord() != e.Record {
+		helper.UpdateRecordFromOther(video.ProxyRecord(), e.Record,
+			"name", "description", "status", "preview", "preview_is_set",
+		)
+	}
+
+	return e.Next()
+}
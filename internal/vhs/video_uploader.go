@@ -1,15 +1,102 @@
 package vhs
 
+import (
+	"errors"
+	"vhs/pkg/checksum"
+)
+
 type VideoUploader interface {
 	Start(*VideoUploadData) (string, error)
+	Resume(*VideoUploadData) (string, error)
 	UploadPart([]byte) (bool, error)
+	Offset() int
+	Truncate(int) error
+	Finish(sha256 []byte) error
+	Suspend() error
 	Cancel() error
 	Done()
 }
 
 type VideoUploadData struct {
-	Size   int    `json:"size"`
-	Name   string `json:"name"`
-	Token  string `json:"token"`
-	UserId string
+	Size    int    `json:"size"`
+	Name    string `json:"name"`
+	Token   string `json:"token"`
+	VideoId string `json:"videoId"`
+	UserId  string
+}
+
+type UploadStatus struct {
+	Name   string
+	Size   int
+	Offset int
+}
+
+// UploadMessage is a text message of the upload protocol.
+// The "part" message announces the checksum of the next binary part,
+// the "end" message carries the SHA-256 of the whole file.
+type UploadMessage struct {
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Checksum  string `json:"checksum"`
+}
+
+const (
+	UploadErrorCodeInternal     = "internal"
+	UploadErrorCodeMessage      = "invalid_message"
+	UploadErrorCodeNotStarted   = "not_started"
+	UploadErrorCodeInProgress   = "in_progress"
+	UploadErrorCodeOffset       = "offset_mismatch"
+	UploadErrorCodeAccess       = "access_denied"
+	UploadErrorCodeSize         = "size_exceeded"
+	UploadErrorCodeIncomplete   = "incomplete"
+	UploadErrorCodeChecksum     = "checksum_mismatch"
+	UploadErrorCodeHash         = "hash_mismatch"
+	UploadErrorCodeHashRequired = "hash_required"
+	UploadErrorCodeUnsupported  = "unsupported_media"
+	UploadErrorCodeQuota        = "quota_exceeded"
+	UploadErrorCodeLimit        = "too_many_uploads"
+)
+
+// UploadError is an error which is reported to the client with its code.
+type UploadError struct {
+	Code string
+	Err  error
+}
+
+func NewUploadError(code string, err error) *UploadError {
+	return &UploadError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func (e *UploadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrUploadNotStarted   = NewUploadError(UploadErrorCodeNotStarted, errors.New("upload is not started"))
+	ErrUploadInProgress   = NewUploadError(UploadErrorCodeInProgress, errors.New("upload is already in progress"))
+	ErrUploadOffset       = NewUploadError(UploadErrorCodeOffset, errors.New("upload offset mismatch"))
+	ErrUploadAccess       = NewUploadError(UploadErrorCodeAccess, errors.New("upload not found"))
+	ErrUploadSize         = NewUploadError(UploadErrorCodeSize, errors.New("upload size exceeded"))
+	ErrUploadIncomplete   = NewUploadError(UploadErrorCodeIncomplete, errors.New("upload is incomplete"))
+	ErrUploadChecksum     = NewUploadError(UploadErrorCodeChecksum, checksum.ErrMismatch)
+	ErrUploadHash         = NewUploadError(UploadErrorCodeHash, errors.New("file hash mismatch"))
+	ErrUploadHashRequired = NewUploadError(UploadErrorCodeHashRequired, errors.New("file hash is required"))
+	ErrUploadQuota        = NewUploadError(UploadErrorCodeQuota, errors.New("storage quota exceeded"))
+	ErrUploadLimit        = NewUploadError(UploadErrorCodeLimit, errors.New("too many uploads at once"))
+)
+
+func UploadErrorCode(err error) string {
+	var uploadErr *UploadError
+	if errors.As(err, &uploadErr) {
+		return uploadErr.Code
+	}
+
+	return UploadErrorCodeInternal
 }
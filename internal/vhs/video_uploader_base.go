@@ -1,16 +1,27 @@
 package vhs
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 	"vhs/internal/assets"
 	"vhs/internal/vhs/entities"
-	"vhs/internal/vhs/helper"
 	"vhs/pkg/errorcollector"
 	"vhs/pkg/ffhelp"
 	"vhs/pkg/webvtt"
 
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
 )
@@ -18,9 +29,11 @@ import (
 type VideoUploaderBase struct {
 	tmpFile      *os.File
 	ffhelp       *ffhelp.FFHelp
+	runner       ffhelp.Runner
 	bytesWritten int
 	data         *VideoUploadData
 	video        Video
+	session      UploadSession
 	logger       *slog.Logger
 }
 
@@ -29,28 +42,182 @@ const (
 	ThumbsDir       = UploadDir + "/thumbs"
 	SpriteSheetsDir = UploadDir + "/sheets"
 	WebVTTDir       = UploadDir + "/webvtt"
+	RenditionsDir   = UploadDir + "/renditions"
+	HLSDir          = UploadDir + "/hls"
+	DASHDir         = UploadDir + "/dash"
+	CandidatesDir   = UploadDir + "/candidates"
+	TeasersDir      = UploadDir + "/teasers"
+	WaveformDir     = UploadDir + "/waveform"
+
+	// HLSFilesDir and DASHFilesDir are the directories of the streaming files
+	// in the storage of the video record.
+	HLSFilesDir  = "hls"
+	DASHFilesDir = "dash"
+
+	HLSSegmentDuration  = 6
+	DASHSegmentDuration = 6
 
 	FrameDuration = 5
 
+	// ThumbnailsKeyframesOnly makes the sprites from the keyframes only,
+	// which is faster but less precise.
+	ThumbnailsKeyframesOnly = false
+
 	DefaultPreviewWidth  = 1280
 	DefaultPreviewHeight = 720
 
+	// PreviewCandidatesCount is the number of frames from which the default preview is chosen.
+	PreviewCandidatesCount = 6
+
+	TeaserSegments        = 5
+	TeaserSegmentDuration = 1
+	TeaserWidth           = 320
+	TeaserHeight          = 180
+	TeaserFPS             = 12
+
+	// WaveformSamplesPerPixel makes 10 peaks per second of the audio.
+	WaveformSamplesPerPixel = ffhelp.PeaksSampleRate / 10
+
 	SpriteSheetCols     = 10
 	SpriteSheetRows     = 40
 	SpriteWidth         = 180
 	SpriteHeight        = 101
 	SpriteSheetImgCount = SpriteSheetCols * SpriteSheetRows
+
+	// UploadSessionTTL is how long an unfinished upload can stay idle
+	// before its session and temporary file are removed.
+	UploadSessionTTL = 24 * time.Hour
+
+	// MaxUploadSize matches the max size of the "video" field of the videos collection.
+	MaxUploadSize = 20 << 30
 )
 
+// activeUploads holds ids of the videos which are currently being written
+// by some connection, so a session can't be resumed twice at the same time.
+var activeUploads = &uploadRegistry{
+	uploads:  make(map[string]string),
+	reserved: make(map[string]uploadReservation),
+}
+
+// uploadRegistry maps the ids of the videos being written to their users,
+// so a user can't write more than Config.MaxUploadsPerUser files at once.
+type uploadRegistry struct {
+	mu      sync.Mutex
+	uploads map[string]string
+	// reserved holds the quota taken by the uploads of each user, which are being started
+	// and whose videos aren't counted in the usage yet
+	reserved map[string]uploadReservation
+}
+
+type uploadReservation struct {
+	bytes  int
+	videos int
+}
+
+func (r *uploadRegistry) acquire(videoId string, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.uploads[videoId]; ok {
+		return ErrUploadInProgress
+	}
+
+	if Config.MaxUploadsPerUser > 0 {
+		count := 0
+		for _, user := range r.uploads {
+			if user == userId {
+				count++
+			}
+		}
+		if count >= Config.MaxUploadsPerUser {
+			return fmt.Errorf("%w: %d uploads at once", ErrUploadLimit, Config.MaxUploadsPerUser)
+		}
+	}
+
+	r.uploads[videoId] = userId
+
+	return nil
+}
+
+func (r *uploadRegistry) release(videoId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.uploads, videoId)
+}
+
+// reserve holds the quota of the upload, if it passes the check
+// with the quota reserved by the other uploads of the user.
+func (r *uploadRegistry) reserve(userId string, upload uploadReservation, check func(reserved uploadReservation) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reserved := r.reserved[userId]
+	if err := check(reserved); err != nil {
+		return err
+	}
+
+	if upload != (uploadReservation{}) {
+		r.reserved[userId] = uploadReservation{
+			bytes:  reserved.bytes + upload.bytes,
+			videos: reserved.videos + upload.videos,
+		}
+	}
+
+	return nil
+}
+
+func (r *uploadRegistry) unreserve(userId string, upload uploadReservation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reserved := uploadReservation{
+		bytes:  r.reserved[userId].bytes - upload.bytes,
+		videos: r.reserved[userId].videos - upload.videos,
+	}
+	if reserved == (uploadReservation{}) {
+		delete(r.reserved, userId)
+	} else {
+		r.reserved[userId] = reserved
+	}
+}
+
+func (r *uploadRegistry) active(videoId string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.uploads[videoId]
+
+	return ok
+}
+
 func NewVideoUploader(logger *slog.Logger) VideoUploader {
 	return &VideoUploaderBase{
+		runner: FFmpeg,
 		logger: logger,
 	}
 }
 
+// NewVideoProcessor returns the uploader of the already received file,
+// which is only able to Process it.
+func NewVideoProcessor(video Video, path string, logger *slog.Logger) (*VideoUploaderBase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VideoUploaderBase{
+		tmpFile: file,
+		video:   video,
+		runner:  FFmpeg,
+		logger:  logger,
+	}, nil
+}
+
 type VideoUploaderBaseMock struct {
 	TmpFile      *os.File
 	Ffhelp       *ffhelp.FFHelp
+	Runner       ffhelp.Runner
 	BytesWritten int
 	Data         *VideoUploadData
 	Video        Video
@@ -61,6 +228,7 @@ func NewVideoUploaderMock(v *VideoUploaderBaseMock) *VideoUploaderBase {
 	return &VideoUploaderBase{
 		tmpFile:      v.TmpFile,
 		ffhelp:       v.Ffhelp,
+		runner:       v.Runner,
 		bytesWritten: v.BytesWritten,
 		data:         v.Data,
 		video:        v.Video,
@@ -69,67 +237,218 @@ func NewVideoUploaderMock(v *VideoUploaderBaseMock) *VideoUploaderBase {
 }
 
 func (v *VideoUploaderBase) Start(data *VideoUploadData) (string, error) {
+	// the upload which is already written by the uploader would never be released
+	if v.tmpFile != nil {
+		return "", ErrUploadInProgress
+	}
+	if data.Size <= 0 {
+		return "", NewUploadError(UploadErrorCodeMessage, errors.New("upload size is required"))
+	}
+	if data.Size > MaxUploadSize {
+		return "", ErrUploadSize
+	}
+	if err := v.checkQuota(data.UserId, data.Size, nil); err != nil {
+		return "", err
+	}
+	// the video of the upload is counted in the usage once it is saved
+	defer activeUploads.unreserve(data.UserId, uploadReservation{bytes: data.Size, videos: 1})
+
+	videoId := core.GenerateDefaultRandomId()
+	if err := activeUploads.acquire(videoId, data.UserId); err != nil {
+		return "", err
+	}
+
+	if err := v.start(videoId, data); err != nil {
+		activeUploads.release(videoId)
+		return "", err
+	}
+
+	return videoId, nil
+}
+
+func (v *VideoUploaderBase) start(videoId string, data *VideoUploadData) error {
 	err := os.MkdirAll(UploadDir, 0755)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	file, err := os.CreateTemp(UploadDir, "video_")
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	col, err := Collections.Get(entities.VideosCollection)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	record := core.NewRecord(col)
+	record.Id = videoId
 	video := NewVideoFromRecord(record)
 	video.SetStatus(entities.StatusClosed)
+	video.SetProcessingState(entities.ProcessingStateUploading, 0)
 	video.SetUser(data.UserId)
 	video.SetName(data.Name)
+	video.SetSize(data.Size)
 	preview, err := filesystem.NewFileFromBytes(assets.DefaultPreview, "default_preview")
 	if err != nil {
-		return "", err
+		return err
 	}
-	video.SetPreview(preview)
+	video.SetGeneratedPreview(preview)
 
 	if err = video.Save(); err != nil {
+		return err
+	}
+
+	session, err := NewUploadSession()
+	if err != nil {
+		return err
+	}
+	session.SetVideo(video.ID())
+	session.SetUser(data.UserId)
+	session.SetName(data.Name)
+	session.SetSize(data.Size)
+	session.SetPath(file.Name())
+
+	if err = session.Save(); err != nil {
+		return err
+	}
+
+	v.tmpFile = file
+	v.video = video
+	v.session = session
+	v.data = data
+	syncedVideos.add(video)
+
+	return nil
+}
+
+// Resume continues an upload which was interrupted before all the bytes were received.
+// The upload continues from the size of the temporary file, see Offset.
+func (v *VideoUploaderBase) Resume(data *VideoUploadData) (string, error) {
+	if v.tmpFile != nil {
+		return "", ErrUploadInProgress
+	}
+
+	session, err := NewUploadSessionOfUser(data.VideoId, data.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	if err = activeUploads.acquire(session.Video(), session.User()); err != nil {
+		return "", err
+	}
+
+	id, err := v.resume(session, data)
+	if err != nil {
+		activeUploads.release(session.Video())
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (v *VideoUploaderBase) resume(session UploadSession, data *VideoUploadData) (string, error) {
+	video, err := NewVideoFromId(session.Video())
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(session.Path(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+
+	data.Name = session.Name()
+	data.Size = session.Size()
+
+	if err = v.checkQuota(data.UserId, data.Size, video); err != nil {
+		file.Close()
 		return "", err
 	}
 
 	v.tmpFile = file
+	v.bytesWritten = int(stat.Size())
 	v.video = video
+	v.session = session
 	v.data = data
-	v.bindHooks()
+	syncedVideos.add(video)
 
 	return video.ID(), nil
 }
 
-func (v *VideoUploaderBase) bindHooks() {
-	PocketBase.
-		OnRecordAfterUpdateSuccess(entities.VideosCollection).
-		BindFunc(func(e *core.RecordEvent) error {
-			if e.Record.Id != v.video.ID() {
-				return e.Next()
-			}
+// checkQuota returns ErrUploadQuota if the upload of the given size doesn't fit into the quotas of the user.
+// The size of the new upload is reserved in activeUploads, so the uploads started at once
+// can't exceed the quotas together, it is released by the caller with unreserve.
+// The resumed video is already counted in the usage of the user.
+func (v *VideoUploaderBase) checkQuota(userId string, size int, resumed Video) error {
+	user, err := NewUserFromId(userId)
+	if err != nil {
+		return err
+	}
 
-			helper.UpdateRecordFromOther(v.video.ProxyRecord(), e.Record,
-				"name", "description", "status", "preview", "preview_is_set",
-			)
+	upload := uploadReservation{bytes: size, videos: 1}
+	if resumed != nil {
+		upload = uploadReservation{}
+	}
 
-			return e.Next()
-		})
+	return activeUploads.reserve(userId, upload, func(reserved uploadReservation) error {
+		usage, err := user.Usage()
+		if err != nil {
+			return err
+		}
+
+		usage.Bytes += reserved.bytes
+		usage.Videos += reserved.videos
+		if resumed != nil {
+			usage.Bytes -= resumed.Size()
+			usage.Videos--
+		}
+
+		if usage.QuotaVideos > 0 && usage.Videos >= usage.QuotaVideos {
+			return fmt.Errorf("%w: %d of %d videos", ErrUploadQuota, usage.Videos, usage.QuotaVideos)
+		}
+
+		if usage.QuotaBytes > 0 {
+			left := max(usage.QuotaBytes-usage.Bytes, 0)
+			if size > left {
+				return fmt.Errorf("%w: %d of %d bytes left", ErrUploadQuota, left, usage.QuotaBytes)
+			}
+		}
+
+		return nil
+	})
 }
 
 func (v *VideoUploaderBase) UploadPart(p []byte) (bool, error) {
+	if v.tmpFile == nil {
+		return false, ErrUploadNotStarted
+	}
+
+	if v.bytesWritten+len(p) > v.data.Size {
+		return false, ErrUploadSize
+	}
+
 	n, err := v.tmpFile.Write(p)
 	if err != nil {
 		return false, err
 	}
+	probed := v.bytesWritten >= Config.ProbeHeadSize
 	v.bytesWritten += n
 
+	// the rest of the file isn't needed to tell that it can't be processed
+	if !probed && v.bytesWritten >= Config.ProbeHeadSize && v.bytesWritten < v.data.Size {
+		if err = v.validate(true); err != nil {
+			return false, err
+		}
+	}
+
 	done := false
 	if v.bytesWritten >= v.data.Size {
 		done = true
@@ -138,33 +457,230 @@ func (v *VideoUploaderBase) UploadPart(p []byte) (bool, error) {
 	return done, nil
 }
 
+// Offset returns the number of bytes already committed to the temporary file.
+func (v *VideoUploaderBase) Offset() int {
+	return v.bytesWritten
+}
+
+// Truncate discards everything written after the given offset.
+func (v *VideoUploaderBase) Truncate(offset int) error {
+	if v.tmpFile == nil {
+		return ErrUploadNotStarted
+	}
+	if offset > v.bytesWritten {
+		return ErrUploadOffset
+	}
+
+	if err := v.tmpFile.Truncate(int64(offset)); err != nil {
+		return err
+	}
+	// the file of a new upload isn't opened for appending
+	if _, err := v.tmpFile.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+	v.bytesWritten = offset
+
+	return nil
+}
+
+// Finish checks that the whole file is received and stores its SHA-256 on the video.
+// If sum is given, it must match the hash of the received file.
+func (v *VideoUploaderBase) Finish(sum []byte) error {
+	if v.tmpFile == nil {
+		return ErrUploadNotStarted
+	}
+	if v.bytesWritten != v.data.Size {
+		return ErrUploadIncomplete
+	}
+
+	hash, err := v.fileHash()
+	if err != nil {
+		return err
+	}
+	if sum != nil && !bytes.Equal(hash, sum) {
+		return ErrUploadHash
+	}
+	if err = v.validate(false); err != nil {
+		return err
+	}
+
+	v.video.SetSha256(hex.EncodeToString(hash))
+
+	return v.video.Save()
+}
+
+// validate probes the received file and rejects it if it can't be processed, see Config.Allowlist.
+// The partial file, which ffprobe can't read yet, isn't rejected,
+// e.g. an MP4 which has the moov atom at the end.
+func (v *VideoUploaderBase) validate(partial bool) error {
+	ff, err := ffhelp.InputWithRunner(v.tmpFile.Name(), v.runner)
+	if err != nil && partial {
+		return nil
+	}
+	if err == nil {
+		err = Config.Allowlist.Check(ff.Probe())
+	}
+	if err == nil {
+		err = v.checkMimeType()
+	}
+	if err != nil {
+		return v.reject(err)
+	}
+
+	return nil
+}
+
+// checkMimeType returns ErrUnsupportedMedia if the file can't be saved to the "video" field,
+// which is checked the same way on save.
+func (v *VideoUploaderBase) checkMimeType() error {
+	col, err := Collections.Get(entities.VideosCollection)
+	if err != nil {
+		return err
+	}
+	field, ok := col.Fields.GetByName("video").(*core.FileField)
+	if !ok || len(field.MimeTypes) == 0 {
+		return nil
+	}
+
+	mime, err := mimetype.DetectFile(v.tmpFile.Name())
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(field.MimeTypes, mime.Is) {
+		return fmt.Errorf("%w: file type %s is not allowed", ffhelp.ErrUnsupportedMedia, mime.String())
+	}
+
+	return nil
+}
+
+// reject stops the upload of the file which can't be processed.
+// The temporary file and the session are removed, the cause is kept on the failed video.
+func (v *VideoUploaderBase) reject(cause error) error {
+	defer v.release()
+
+	v.logger.Warn(
+		"upload is rejected: "+cause.Error(),
+		"video", v.video,
+	)
+
+	v.video.SetProcessingState(entities.ProcessingStateFailed, 0)
+	v.video.SetProcessingError(processingErrorMessage(cause))
+	var ffErr *ffhelp.Error
+	if errors.As(cause, &ffErr) {
+		v.video.SetFFmpegError(ffErr)
+	}
+
+	ec := errorcollector.NewErrorCollector()
+	ec.Collect(v.clear)
+	ec.Collect(v.session.Delete)
+	ec.Collect(v.video.Save)
+	v.tmpFile = nil
+
+	return errors.Join(NewUploadError(UploadErrorCodeUnsupported, cause), ec.Error())
+}
+
+func (v *VideoUploaderBase) fileHash() ([]byte, error) {
+	f, err := os.Open(v.tmpFile.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// release lets the upload be resumed by another connection and stops syncing its video.
+func (v *VideoUploaderBase) release() {
+	activeUploads.release(v.video.ID())
+	syncedVideos.remove(v.video)
+}
+
+// Suspend releases the upload without removing its session,
+// so it can be continued later with Resume.
+func (v *VideoUploaderBase) Suspend() error {
+	if v.tmpFile == nil {
+		return nil
+	}
+
+	defer v.release()
+
+	return v.tmpFile.Close()
+}
+
 func (v *VideoUploaderBase) Cancel() error {
-	return v.clear()
+	if v.tmpFile == nil {
+		return nil
+	}
+
+	defer v.release()
+
+	// the session is removed by the cascade delete of the video
+	ec := errorcollector.NewErrorCollector()
+	ec.Collect(v.clear)
+	ec.Collect(v.video.Delete)
+	v.tmpFile = nil
+
+	return ec.Error()
 }
 
+// Done finishes the upload and queues the processing of the received file.
+// The session is only removed once the file is queued, otherwise the video fails
+// and the file is left to CleanupUploadSessions.
 func (v *VideoUploaderBase) Done() {
-	go v.done()
+	defer v.release()
+
+	err := errors.Join(v.tmpFile.Close(), v.enqueue())
+	if err != nil {
+		v.logger.Error(
+			"error while finishing upload: "+err.Error(),
+			"video", v.video,
+		)
+	}
 }
 
-func (v *VideoUploaderBase) done() error {
-	var err error
+func (v *VideoUploaderBase) enqueue() error {
+	// the state is saved before the job, which can be taken by a worker at once
+	err := v.SetProcessingState(entities.ProcessingStateQueued, 0)
+	if err == nil {
+		err = Jobs.Enqueue(v.video.ID(), v.tmpFile.Name())
+	}
+	if err != nil {
+		v.video.SetProcessingState(entities.ProcessingStateFailed, 0)
+		v.video.SetProcessingError(processingErrorMessage(err))
+
+		return errors.Join(err, v.video.Save())
+	}
+
+	return v.session.Delete()
+}
+
+// Process runs all the processing steps of the uploaded file.
+// The leftovers of the previous attempt are removed before the start.
+func (v *VideoUploaderBase) Process() (err error) {
+	syncedVideos.add(v.video)
+	defer syncedVideos.remove(v.video)
+
+	if err = v.clearWorkDirs(); err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, v.clearWorkDirs())
+	}()
 	defer func() {
 		if err != nil {
-			v.logger.Error(
-				"error while video processing: "+err.Error(),
-				"video", v.video,
-			)
-		}
-
-		if err = v.clear(); err != nil {
-			v.logger.Error(
-				"error while clearing video files: "+err.Error(),
-				"video", v.video,
-			)
+			v.setFFmpegError(err)
 		}
 	}()
 
-	if v.ffhelp, err = ffhelp.Input(v.tmpFile.Name()); err != nil {
+	if err = v.SetProcessingState(entities.ProcessingStateProbing, 0); err != nil {
+		return err
+	}
+	if v.ffhelp, err = ffhelp.InputWithRunner(v.tmpFile.Name(), v.runner); err != nil {
 		return err
 	}
 	if err = v.SetMeta(); err != nil {
@@ -176,22 +692,145 @@ func (v *VideoUploaderBase) done() error {
 	if err = v.SaveVideoFile(); err != nil {
 		return err
 	}
+	if v.video.MediaKind() == entities.MediaKindAudio {
+		return v.processAudio()
+	}
+	if err = v.SetProcessingState(entities.ProcessingStateThumbnails, 5); err != nil {
+		return err
+	}
 	if err = v.CreateSprites(); err != nil {
 		return err
 	}
+	if err = v.SetProcessingState(entities.ProcessingStateSheets, 30); err != nil {
+		return err
+	}
 	if err = v.CreateSpriteSheet(); err != nil {
 		return err
 	}
+	if err = v.SetProcessingState(entities.ProcessingStateWebVTT, 35); err != nil {
+		return err
+	}
 	if err = v.CreateWebVTT(); err != nil {
 		return err
 	}
+	if err = v.SetProcessingState(entities.ProcessingStatePreview, 40); err != nil {
+		return err
+	}
 	if err = v.SetDefaultPreview(); err != nil {
 		return err
 	}
+	if err = v.SetProcessingState(entities.ProcessingStateTeaser, 43); err != nil {
+		return err
+	}
+	if err = v.CreateTeaser(); err != nil {
+		return err
+	}
+	if err = v.SetProcessingState(entities.ProcessingStateWaveform, 44); err != nil {
+		return err
+	}
+	if err = v.CreateWaveform(); err != nil {
+		return err
+	}
+	if err = v.SetProcessingState(entities.ProcessingStateTranscoding, 45); err != nil {
+		return err
+	}
+	if err = v.CreateStreams(); err != nil {
+		return err
+	}
+	if err = v.SetProcessingState(entities.ProcessingStateReady, 100); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// processAudio runs the steps which make sense for an audio without a video stream:
+// there are no sprites, teasers or renditions, and the preview is made of the cover art or the waveform.
+func (v *VideoUploaderBase) processAudio() error {
+	if err := v.SetProcessingState(entities.ProcessingStatePreview, 40); err != nil {
+		return err
+	}
+	if err := v.SetAudioPreview(); err != nil {
+		return err
+	}
+	if err := v.SetProcessingState(entities.ProcessingStateWaveform, 44); err != nil {
+		return err
+	}
+	if err := v.CreateWaveform(); err != nil {
+		return err
+	}
+
+	return v.SetProcessingState(entities.ProcessingStateReady, 100)
+}
+
+// SetProcessingState saves the current processing step, so it is sent to the realtime subscribers.
+func (v *VideoUploaderBase) SetProcessingState(state entities.ProcessingState, progress float64) error {
+	v.video.SetProcessingState(state, progress)
+	if state == entities.ProcessingStateReady {
+		v.video.SetProcessingError("")
+		v.video.SetFFmpegError(nil)
+	}
+
+	return v.video.Save()
+}
+
+// processingErrorMessage is the error shown to the users,
+// the details of the ffmpeg failure are only kept in FFmpegError.
+func processingErrorMessage(err error) string {
+	var ffErr *ffhelp.Error
+	if errors.As(err, &ffErr) {
+		return ffErr.Summary()
+	}
+
+	return err.Error()
+}
+
+// setFFmpegError records the ffmpeg failure on the video, so the bad file can be diagnosed.
+func (v *VideoUploaderBase) setFFmpegError(err error) {
+	var ffErr *ffhelp.Error
+	if !errors.As(err, &ffErr) {
+		return
+	}
+
+	v.logger.Error(
+		"ffmpeg error while processing video: "+ffErr.Error(),
+		"video", v.video,
+		"args", ffErr.Args,
+		"stderr", ffErr.Stderr,
+	)
+
+	v.video.SetFFmpegError(ffErr)
+	if err = v.video.Save(); err != nil {
+		v.logger.Error(
+			"error while saving ffmpeg error: "+err.Error(),
+			"video", v.video,
+		)
+	}
+}
+
+// progress returns the function which reports the progress of the current step
+// as a part of the processing from the start to the end percent.
+// The video is saved only when the progress changes by a whole percent.
+func (v *VideoUploaderBase) progress(start, end float64) ffhelp.ProgressFunc {
+	state := v.video.ProcessingState()
+	saved := math.Floor(start)
+
+	return func(p float64) {
+		current := math.Floor(start + (end-start)*p)
+		if current <= saved {
+			return
+		}
+		saved = current
+
+		if err := v.SetProcessingState(state, current); err != nil {
+			v.logger.Error(
+				"error while saving processing progress: "+err.Error(),
+				"video", v.video,
+			)
+		}
+	}
+}
+
 func (v *VideoUploaderBase) clear() error {
 	ec := errorcollector.NewErrorCollector()
 
@@ -205,11 +844,24 @@ func (v *VideoUploaderBase) clear() error {
 	}()
 
 	ec.Collect(func() error {
-		return v.tmpFile.Close()
+		err := v.tmpFile.Close()
+		if errors.Is(err, os.ErrClosed) {
+			return nil
+		}
+		return err
 	})
 	ec.Collect(func() error {
 		return os.Remove(v.tmpFile.Name())
 	})
+	ec.Collect(v.clearWorkDirs)
+
+	return ec.Error()
+}
+
+// clearWorkDirs removes the files created during the processing.
+func (v *VideoUploaderBase) clearWorkDirs() error {
+	ec := errorcollector.NewErrorCollector()
+
 	ec.Collect(func() error {
 		return os.RemoveAll(v.thumbsDir())
 	})
@@ -219,6 +871,27 @@ func (v *VideoUploaderBase) clear() error {
 	ec.Collect(func() error {
 		return os.RemoveAll(v.webvttDir())
 	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.defaultPreviewPath())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.candidatesDir())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.teasersDir())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.waveformPath())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.renditionsDir())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.hlsDir())
+	})
+	ec.Collect(func() error {
+		return os.RemoveAll(v.dashDir())
+	})
 
 	return ec.Error()
 }
@@ -234,12 +907,22 @@ func (v *VideoUploaderBase) SaveVideoFile() error {
 	return v.video.Save()
 }
 
+// CreateSprites takes the thumbnails of the video in a single pass,
+// tiling them right into the sprite sheets.
 func (v *VideoUploaderBase) CreateSprites() error {
-	err := v.ffhelp.SplitVideoToThumbnails(
-		v.thumbsDir(),
-		FrameDuration,
-		SpriteWidth,
-		SpriteHeight,
+	width, height := v.spriteSize()
+
+	err := v.ffhelp.ExtractThumbnails(
+		v.sheetsDir(),
+		ffhelp.ThumbnailsOptions{
+			FrameDuration: FrameDuration,
+			Width:         width,
+			Height:        height,
+			KeyframesOnly: ThumbnailsKeyframesOnly,
+			Cols:          SpriteSheetCols,
+			Rows:          SpriteSheetRows,
+		},
+		v.progress(5, 30),
 	)
 	if err != nil {
 		return err
@@ -249,33 +932,14 @@ func (v *VideoUploaderBase) CreateSprites() error {
 }
 
 func (v *VideoUploaderBase) CreateSpriteSheet() error {
-	entries, err := v.readThumbsDir()
-
-	sheetsCount := (len(entries) + SpriteSheetImgCount - 1) / SpriteSheetImgCount
-	filePaths := make([][]string, sheetsCount)
-	i := 0
-	for j, entry := range entries {
-		filePaths[i] = append(filePaths[i], v.thumbsDir()+"/"+entry.Name())
-
-		if j%SpriteSheetImgCount == SpriteSheetImgCount-1 {
-			i++
-		}
+	entries, err := os.ReadDir(v.sheetsDir())
+	if err != nil {
+		return err
 	}
 
 	var files []*filesystem.File
-	for i := 0; i < sheetsCount; i++ {
-		sheetPath := fmt.Sprintf("%s/sheet%06d.jpg", v.sheetsDir(), i)
-
-		err = webvtt.CreateSpriteSheet(
-			filePaths[i],
-			sheetPath,
-			SpriteSheetCols, SpriteSheetRows, SpriteWidth, SpriteHeight,
-		)
-		if err != nil {
-			return err
-		}
-
-		f, err := filesystem.NewFileFromPath(sheetPath)
+	for _, entry := range entries {
+		f, err := filesystem.NewFileFromPath(v.sheetsDir() + "/" + entry.Name())
 		if err != nil {
 			return err
 		}
@@ -294,13 +958,15 @@ func (v *VideoUploaderBase) CreateWebVTT() error {
 		sheetPaths = append(sheetPaths, "/api/files/"+v.video.BaseFilesPath()+"/"+imgId)
 	}
 
+	width, height := v.spriteSize()
+
 	outFile := fmt.Sprintf("%s/webvtt.vtt", v.webvttDir())
 	file, err := webvtt.CreateFromSheets(
 		sheetPaths,
 		outFile,
 		int(v.ffhelp.GetVideoDuration()),
 		FrameDuration,
-		v.thumbsCount(), SpriteSheetCols, SpriteSheetRows, SpriteWidth, SpriteHeight,
+		v.thumbsCount(), SpriteSheetCols, SpriteSheetRows, width, height,
 	)
 	if err != nil {
 		return err
@@ -316,18 +982,67 @@ func (v *VideoUploaderBase) CreateWebVTT() error {
 	return v.video.Save()
 }
 
+// SetDefaultPreview saves the preview candidates of the video
+// and sets the best of them as the preview, unless it was set by the user.
 func (v *VideoUploaderBase) SetDefaultPreview() error {
-	// If the preview isn't empty, this means it was set by the user.
+	frames, err := v.ffhelp.PreviewCandidates(
+		v.candidatesDir(),
+		PreviewCandidatesCount,
+		DefaultPreviewWidth,
+		DefaultPreviewHeight,
+	)
+	if err != nil {
+		return err
+	}
+
+	candidates := make([]*entities.PreviewCandidate, 0, len(frames))
+	files := make([]*filesystem.File, 0, len(frames))
+	for _, frame := range frames {
+		f, err := filesystem.NewFileFromPath(frame.Path)
+		if err != nil {
+			return err
+		}
+
+		candidates = append(candidates, &entities.PreviewCandidate{
+			Time:  frame.Time,
+			Score: frame.Score,
+		})
+		files = append(files, f)
+	}
+
+	v.video.SetPreviewCandidates(candidates, files)
+
+	if best := ffhelp.BestFrame(frames); best != nil && !v.video.PreviewIsSet() {
+		f, err := filesystem.NewFileFromPath(best.Path)
+		if err != nil {
+			return err
+		}
+
+		v.video.SetGeneratedPreview(f)
+	}
+
+	return v.video.Save()
+}
+
+// SetAudioPreview sets the cover art of the audio as its preview,
+// or the picture of its waveform if there is no cover art, unless the preview was set by the user.
+func (v *VideoUploaderBase) SetAudioPreview() error {
 	if v.video.PreviewIsSet() {
 		return nil
 	}
 
-	file, err := v.ffhelp.SaveFrame(
-		v.defaultPreviewPath(),
-		v.ffhelp.GetVideoDuration()/2,
-		DefaultPreviewWidth,
-		DefaultPreviewHeight,
+	var (
+		file *os.File
+		err  error
 	)
+	if v.ffhelp.AttachedPicture() != nil {
+		file, err = v.ffhelp.SaveAttachedPicture(v.defaultPreviewPath(), DefaultPreviewWidth, DefaultPreviewHeight)
+	} else {
+		file, err = v.ffhelp.SaveWaveformImage(v.defaultPreviewPath(), DefaultPreviewWidth, DefaultPreviewHeight)
+	}
+	if errors.Is(err, ffhelp.ErrNoAudio) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -338,18 +1053,148 @@ func (v *VideoUploaderBase) SetDefaultPreview() error {
 		return err
 	}
 
-	v.video.SetPreview(f)
+	v.video.SetGeneratedPreview(f)
+
+	return v.video.Save()
+}
+
+// CreateTeaser creates the animated WebP and the muted MP4 teasers shown on hover.
+func (v *VideoUploaderBase) CreateTeaser() error {
+	mp4File := v.teasersDir() + "/teaser.mp4"
+	webpFile := v.teasersDir() + "/teaser.webp"
+
+	err := v.ffhelp.CreateTeaser(mp4File, webpFile, ffhelp.TeaserOptions{
+		Segments:        TeaserSegments,
+		SegmentDuration: TeaserSegmentDuration,
+		Width:           TeaserWidth,
+		Height:          TeaserHeight,
+		FPS:             TeaserFPS,
+	})
+	if err != nil {
+		return err
+	}
+
+	mp4, err := filesystem.NewFileFromPath(mp4File)
+	if err != nil {
+		return err
+	}
+	webp, err := filesystem.NewFileFromPath(webpFile)
+	if err != nil {
+		return err
+	}
+
+	v.video.SetTeaserMP4(mp4)
+	v.video.SetTeaserWebP(webp)
 
 	return v.video.Save()
 }
 
+// CreateWaveform stores the audio peaks for the waveform of the player.
+// A video without audio gets no waveform.
+func (v *VideoUploaderBase) CreateWaveform() error {
+	peaks, err := v.ffhelp.Peaks(WaveformSamplesPerPixel)
+	if errors.Is(err, ffhelp.ErrNoAudio) {
+		v.video.SetWaveform(nil)
+		return v.video.Save()
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(peaks)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(WaveformDir, 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(v.waveformPath(), data, 0644); err != nil {
+		return err
+	}
+
+	f, err := filesystem.NewFileFromPath(v.waveformPath())
+	if err != nil {
+		return err
+	}
+
+	v.video.SetWaveform(f)
+
+	return v.video.Save()
+}
+
+// CreateStreams transcodes the video to the renditions of the default ladder
+// and stores their HLS and DASH segments with the video files.
+func (v *VideoUploaderBase) CreateStreams() error {
+	renditions := v.ffhelp.Renditions(ffhelp.DefaultRenditions)
+
+	err := v.ffhelp.Transcode(v.renditionsDir(), renditions, v.progress(45, 97))
+	if err != nil {
+		return err
+	}
+
+	err = v.ffhelp.CreateHLS(v.renditionsDir(), v.hlsDir(), renditions, HLSSegmentDuration)
+	if err != nil {
+		return err
+	}
+
+	err = v.ffhelp.CreateDASH(v.renditionsDir(), v.dashDir(), renditions, DASHSegmentDuration)
+	if err != nil {
+		return err
+	}
+
+	if err = v.uploadDir(v.hlsDir(), HLSFilesDir); err != nil {
+		return err
+	}
+	if err = v.uploadDir(v.dashDir(), DASHFilesDir); err != nil {
+		return err
+	}
+
+	v.video.SetRenditions(renditions)
+
+	return v.video.Save()
+}
+
+// uploadDir stores all the files of the local directory to the filesDir of the video storage.
+func (v *VideoUploaderBase) uploadDir(dir string, filesDir string) error {
+	fs, err := PocketBase.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := filesystem.NewFileFromPath(path)
+		if err != nil {
+			return err
+		}
+
+		return fs.UploadFile(file, v.video.BaseFilesPath()+"/"+filesDir+"/"+filepath.ToSlash(rel))
+	})
+}
+
 func (v *VideoUploaderBase) SetMeta() error {
 	v.video.SetMeta(v.ffhelp.Probe())
+	if v.ffhelp.HasVideo() {
+		v.video.SetMediaKind(entities.MediaKindVideo)
+	} else {
+		v.video.SetMediaKind(entities.MediaKindAudio)
+	}
+
 	return v.video.Save()
 }
 
 func (v *VideoUploaderBase) SetDuration() error {
-	v.video.SetDuration(v.ffhelp.GetVideoDuration())
+	v.video.SetDuration(v.ffhelp.GetDuration())
 	return v.video.Save()
 }
 
@@ -365,19 +1210,40 @@ func (v *VideoUploaderBase) webvttDir() string {
 	return WebVTTDir + "/" + v.video.ID()
 }
 
+func (v *VideoUploaderBase) renditionsDir() string {
+	return RenditionsDir + "/" + v.video.ID()
+}
+
+func (v *VideoUploaderBase) hlsDir() string {
+	return HLSDir + "/" + v.video.ID()
+}
+
+func (v *VideoUploaderBase) dashDir() string {
+	return DASHDir + "/" + v.video.ID()
+}
+
+func (v *VideoUploaderBase) candidatesDir() string {
+	return CandidatesDir + "/" + v.video.ID()
+}
+
+func (v *VideoUploaderBase) teasersDir() string {
+	return TeasersDir + "/" + v.video.ID()
+}
+
+func (v *VideoUploaderBase) waveformPath() string {
+	return WaveformDir + "/" + v.video.ID() + ".json"
+}
+
 func (v *VideoUploaderBase) defaultPreviewPath() string {
 	return UploadDir + "/" + "preview_" + v.video.ID() + ".jpg"
 }
 
-func (v *VideoUploaderBase) readThumbsDir() ([]os.DirEntry, error) {
-	return os.ReadDir(v.thumbsDir())
+// spriteSize returns the size of the sprites with the aspect ratio of the video,
+// which fits into SpriteWidth x SpriteHeight.
+func (v *VideoUploaderBase) spriteSize() (int, int) {
+	return v.ffhelp.FitSize(SpriteWidth, SpriteHeight)
 }
 
 func (v *VideoUploaderBase) thumbsCount() int {
-	entries, err := v.readThumbsDir()
-	if err != nil {
-		return 0
-	}
-
-	return len(entries)
+	return v.ffhelp.ThumbnailsCount(FrameDuration)
 }
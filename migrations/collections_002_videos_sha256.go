@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text3476617281",
+			"max": 64,
+			"min": 0,
+			"name": "sha256",
+			"pattern": "^[a-f0-9]*$",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("text3476617281")
+
+		return app.Save(collection)
+	})
+}
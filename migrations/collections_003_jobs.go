@@ -0,0 +1,146 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_515447164",
+					"hidden": false,
+					"id": "relation1872009285",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "video",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": true,
+					"id": "text2359244304",
+					"max": 0,
+					"min": 0,
+					"name": "file",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select2744374011",
+					"maxSelect": 1,
+					"name": "state",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"queued",
+						"running",
+						"failed",
+						"succeeded"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "number2520571400",
+					"max": null,
+					"min": 0,
+					"name": "attempts",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1574812785",
+					"max": 0,
+					"min": 0,
+					"name": "error",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				}
+			],
+			"id": "pbc_1585466237",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_jobs_state_created` + "`" + ` ON ` + "`" + `jobs` + "`" + ` (` + "`" + `state` + "`" + `, ` + "`" + `created` + "`" + `)"
+			],
+			"listRule": "@request.auth.id = video.user",
+			"name": "jobs",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": "@request.auth.id = video.user"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1585466237")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "number2108389387",
+			"max": 100,
+			"min": 0,
+			"name": "processing_progress",
+			"onlyInt": false,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text2844951016",
+			"max": 0,
+			"min": 0,
+			"name": "processing_error",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("select1211339530")
+		collection.Fields.RemoveById("number2108389387")
+		collection.Fields.RemoveById("text2844951016")
+
+		return app.Save(collection)
+	})
+}
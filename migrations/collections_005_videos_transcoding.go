@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"transcoding",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file2617436520",
+			"maxSelect": 10,
+			"maxSize": 0,
+			"mimeTypes": [
+				"image/jpeg"
+			],
+			"name": "preview_candidates",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [
+				"320x0"
+			],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("file2617436520")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file1850307271",
+			"maxSelect": 1,
+			"maxSize": 0,
+			"mimeTypes": [
+				"image/webp"
+			],
+			"name": "teaser_webp",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file2590837645",
+			"maxSelect": 1,
+			"maxSize": 0,
+			"mimeTypes": [
+				"video/mp4"
+			],
+			"name": "teaser_mp4",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"teaser",
+				"transcoding",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("file1850307271")
+		collection.Fields.RemoveById("file2590837645")
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"transcoding",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
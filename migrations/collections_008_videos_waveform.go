@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file2426218616",
+			"maxSelect": 1,
+			"maxSize": 0,
+			"mimeTypes": [
+				"application/json"
+			],
+			"name": "waveform",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"teaser",
+				"waveform",
+				"transcoding",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("file2426218616")
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select1211339530",
+			"maxSelect": 1,
+			"name": "processing_state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"uploading",
+				"queued",
+				"probing",
+				"thumbnails",
+				"sheets",
+				"webvtt",
+				"preview",
+				"teaser",
+				"transcoding",
+				"ready",
+				"failed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select2470812541",
+			"maxSelect": 1,
+			"name": "media_kind",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"video",
+				"audio"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file2093472300",
+			"maxSelect": 1,
+			"maxSize": 21474836480,
+			"mimeTypes": [
+				"video/mp4",
+				"audio/mpeg",
+				"audio/mp4",
+				"audio/x-m4a",
+				"audio/aac",
+				"audio/flac",
+				"audio/ogg",
+				"audio/wav",
+				"audio/x-wav"
+			],
+			"name": "video",
+			"presentable": false,
+			"protected": true,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("select2470812541")
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "file2093472300",
+			"maxSelect": 1,
+			"maxSize": 21474836480,
+			"mimeTypes": [
+				"video/mp4"
+			],
+			"name": "video",
+			"presentable": false,
+			"protected": true,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
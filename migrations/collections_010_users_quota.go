@@ -0,0 +1,107 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		videos, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = videos.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "number1835291473",
+			"max": null,
+			"min": 0,
+			"name": "size",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = app.Save(videos); err != nil {
+			return err
+		}
+
+		// the size of the already processed videos is known from their probe
+		_, err = app.DB().NewQuery(`
+			UPDATE videos
+			SET size = CAST(json_extract(info, '$.meta.format.size') AS INTEGER)
+			WHERE size = 0 AND json_extract(info, '$.meta.format.size') IS NOT NULL
+		`).Execute()
+		if err != nil {
+			return err
+		}
+
+		users, err := app.FindCollectionByNameOrId("_pb_users_auth_")
+		if err != nil {
+			return err
+		}
+
+		if err = users.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "number3093924592",
+			"max": null,
+			"min": 0,
+			"name": "quota_bytes",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = users.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "number2609513478",
+			"max": null,
+			"min": 0,
+			"name": "quota_videos",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// the quotas are set by the superusers only
+		users.UpdateRule = types.Pointer("id = @request.auth.id && @request.body.quota_bytes:isset = false && @request.body.quota_videos:isset = false")
+
+		return app.Save(users)
+	}, func(app core.App) error {
+		videos, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		videos.Fields.RemoveById("number1835291473")
+
+		if err = app.Save(videos); err != nil {
+			return err
+		}
+
+		users, err := app.FindCollectionByNameOrId("_pb_users_auth_")
+		if err != nil {
+			return err
+		}
+
+		users.Fields.RemoveById("number3093924592")
+		users.Fields.RemoveById("number2609513478")
+		users.UpdateRule = types.Pointer("id = @request.auth.id")
+
+		return app.Save(users)
+	})
+}
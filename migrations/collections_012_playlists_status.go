@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": false,
+			"id": "select2741359372",
+			"maxSelect": 1,
+			"name": "status",
+			"presentable": false,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"public",
+				"link",
+				"closed"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		// the link playlists can be viewed by id, but aren't listed
+		collection.ListRule = types.Pointer(`@request.auth.id = user.id || status = "public"`)
+		collection.ViewRule = types.Pointer(`@request.auth.id = user.id || status = "public" || status = "link"`)
+
+		if err = app.Save(collection); err != nil {
+			return err
+		}
+
+		// the existing playlists stay as visible as they were
+		_, err = app.DB().NewQuery(`UPDATE playlists SET status = 'public' WHERE status = ''`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("select2741359372")
+		collection.ListRule = types.Pointer("")
+		collection.ViewRule = types.Pointer("")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,269 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		membersJsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_976091127",
+					"hidden": false,
+					"id": "relation3299389722",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "playlist",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation2375276105",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "user",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select1466534506",
+					"maxSelect": 1,
+					"name": "role",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"viewer",
+						"contributor",
+						"editor"
+					]
+				}
+			],
+			"id": "pbc_2813541307",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_playlist_members_playlist_user` + "`" + ` ON ` + "`" + `playlist_members` + "`" + ` (` + "`" + `playlist` + "`" + `, ` + "`" + `user` + "`" + `)"
+			],
+			"listRule": "@request.auth.id = playlist.user || playlist.playlist_members_via_playlist.user ?= @request.auth.id",
+			"name": "playlist_members",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": "@request.auth.id = playlist.user || playlist.playlist_members_via_playlist.user ?= @request.auth.id"
+		}`
+
+		members := &core.Collection{}
+		if err := json.Unmarshal([]byte(membersJsonData), &members); err != nil {
+			return err
+		}
+		if err := app.Save(members); err != nil {
+			return err
+		}
+
+		// the actor and the member aren't required, so the log stays after the users are deleted
+		changesJsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_976091127",
+					"hidden": false,
+					"id": "relation3299389722",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "playlist",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation1542800728",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "actor",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": false,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation2375276105",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "user",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select1204587666",
+					"maxSelect": 1,
+					"name": "action",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"invite",
+						"role",
+						"remove"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "select1466534506",
+					"maxSelect": 1,
+					"name": "role",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "select",
+					"values": [
+						"viewer",
+						"contributor",
+						"editor"
+					]
+				}
+			],
+			"id": "pbc_1046711943",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_playlist_member_changes_playlist_created` + "`" + ` ON ` + "`" + `playlist_member_changes` + "`" + ` (` + "`" + `playlist` + "`" + `, ` + "`" + `created` + "`" + `)"
+			],
+			"listRule": "@request.auth.id = playlist.user",
+			"name": "playlist_member_changes",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": "@request.auth.id = playlist.user"
+		}`
+
+		changes := &core.Collection{}
+		if err := json.Unmarshal([]byte(changesJsonData), &changes); err != nil {
+			return err
+		}
+		if err := app.Save(changes); err != nil {
+			return err
+		}
+
+		playlists, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		// the members can view the playlist regardless of its status,
+		// the auth id check keeps the guests out of the playlists without members
+		playlists.ListRule = types.Pointer(`@request.auth.id = user.id || status = "public" || (@request.auth.id != "" && playlist_members_via_playlist.user ?= @request.auth.id)`)
+		playlists.ViewRule = types.Pointer(`@request.auth.id = user.id || status = "public" || status = "link" || (@request.auth.id != "" && playlist_members_via_playlist.user ?= @request.auth.id)`)
+
+		return app.Save(playlists)
+	}, func(app core.App) error {
+		playlists, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		playlists.ListRule = types.Pointer(`@request.auth.id = user.id || status = "public"`)
+		playlists.ViewRule = types.Pointer(`@request.auth.id = user.id || status = "public" || status = "link"`)
+
+		if err = app.Save(playlists); err != nil {
+			return err
+		}
+
+		for _, id := range []string{"pbc_1046711943", "pbc_2813541307"} {
+			collection, err := app.FindCollectionByNameOrId(id)
+			if err != nil {
+				return err
+			}
+
+			if err = app.Delete(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		fields := []string{
+			`{
+				"hidden": false,
+				"id": "select2363381545",
+				"maxSelect": 1,
+				"name": "kind",
+				"presentable": false,
+				"required": false,
+				"system": false,
+				"type": "select",
+				"values": [
+					"manual",
+					"smart"
+				]
+			}`,
+			`{
+				"autogeneratePattern": "",
+				"hidden": false,
+				"id": "text2530025553",
+				"max": 0,
+				"min": 0,
+				"name": "filter",
+				"pattern": "",
+				"presentable": false,
+				"primaryKey": false,
+				"required": false,
+				"system": false,
+				"type": "text"
+			}`,
+			`{
+				"autogeneratePattern": "",
+				"hidden": false,
+				"id": "text1320014936",
+				"max": 0,
+				"min": 0,
+				"name": "sort",
+				"pattern": "",
+				"presentable": false,
+				"primaryKey": false,
+				"required": false,
+				"system": false,
+				"type": "text"
+			}`,
+			`{
+				"hidden": false,
+				"id": "number2378425519",
+				"max": null,
+				"min": 0,
+				"name": "limit",
+				"onlyInt": true,
+				"presentable": false,
+				"required": false,
+				"system": false,
+				"type": "number"
+			}`,
+			`{
+				"autogeneratePattern": "",
+				"hidden": true,
+				"id": "text3911651497",
+				"max": 0,
+				"min": 0,
+				"name": "preview_video",
+				"pattern": "",
+				"presentable": false,
+				"primaryKey": false,
+				"required": false,
+				"system": false,
+				"type": "text"
+			}`,
+		}
+		for _, field := range fields {
+			if err = collection.Fields.AddMarshaledJSON([]byte(field)); err != nil {
+				return err
+			}
+		}
+
+		if err = app.Save(collection); err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery(`UPDATE playlists SET kind = 'manual' WHERE kind = ''`).Execute()
+
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_976091127")
+		if err != nil {
+			return err
+		}
+
+		for _, id := range []string{"select2363381545", "text2530025553", "text1320014936", "number2378425519", "text3911651497"} {
+			collection.Fields.RemoveById(id)
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		if err = collection.Fields.AddMarshaledJSON([]byte(`{
+			"hidden": true,
+			"id": "json1574812785",
+			"maxSize": 0,
+			"name": "ffmpeg_error",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		if err = app.Save(collection); err != nil {
+			return err
+		}
+
+		// the ffmpeg errors were kept in the public info
+		records, err := app.FindAllRecords(collection, dbx.NewExp("json_extract([[info]], '$.ffmpegError') IS NOT NULL"))
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			info := map[string]any{}
+			if err = record.UnmarshalJSONField("info", &info); err != nil {
+				return err
+			}
+
+			record.Set("ffmpeg_error", info["ffmpegError"])
+			delete(info, "ffmpegError")
+			record.Set("info", info)
+
+			if err = app.SaveNoValidate(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_515447164")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveById("json1574812785")
+
+		return app.Save(collection)
+	})
+}
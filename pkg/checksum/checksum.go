@@ -0,0 +1,74 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+)
+
+const (
+	AlgorithmCRC32C = "crc32c"
+	AlgorithmMD5    = "md5"
+	AlgorithmSHA1   = "sha1"
+	AlgorithmSHA256 = "sha256"
+)
+
+var (
+	ErrMismatch             = errors.New("checksum mismatch")
+	ErrUnsupportedAlgorithm = errors.New("unsupported checksum algorithm")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+var algorithms = map[string]func() hash.Hash{
+	AlgorithmCRC32C: func() hash.Hash {
+		return crc32.New(crc32cTable)
+	},
+	AlgorithmMD5:    md5.New,
+	AlgorithmSHA1:   sha1.New,
+	AlgorithmSHA256: sha256.New,
+}
+
+func Algorithms() []string {
+	return []string{AlgorithmCRC32C, AlgorithmMD5, AlgorithmSHA1, AlgorithmSHA256}
+}
+
+func New(algorithm string) (hash.Hash, error) {
+	fn, ok := algorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return fn(), nil
+}
+
+// Verifier hashes everything written to it and compares the result with the expected sum.
+type Verifier struct {
+	hash.Hash
+	sum []byte
+}
+
+func NewVerifier(algorithm string, sum []byte) (*Verifier, error) {
+	h, err := New(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{
+		Hash: h,
+		sum:  sum,
+	}, nil
+}
+
+func (v *Verifier) Verify() error {
+	if !bytes.Equal(v.Sum(nil), v.sum) {
+		return ErrMismatch
+	}
+
+	return nil
+}
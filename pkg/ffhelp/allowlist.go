@@ -0,0 +1,52 @@
+package ffhelp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrUnsupportedMedia = errors.New("unsupported media")
+
+// Allowlist is the containers and codecs which can be processed.
+// The containers are the names of the ffprobe format, e.g. "mp4" matches "mov,mp4,m4a,3gp,3g2,mj2",
+// so the container alone doesn't tell an MP4 from a QuickTime or a 3GP file.
+type Allowlist struct {
+	Containers  []string
+	VideoCodecs []string
+	AudioCodecs []string
+}
+
+// Check returns ErrUnsupportedMedia if the container or a codec of the probed file isn't allowed,
+// or if the file has neither video nor audio. The cover art and other streams aren't checked.
+func (a *Allowlist) Check(p *Probe) error {
+	names := strings.Split(p.Format.FormatName, ",")
+	if !slices.ContainsFunc(names, func(name string) bool {
+		return slices.Contains(a.Containers, name)
+	}) {
+		return fmt.Errorf("%w: container %s is not allowed", ErrUnsupportedMedia, p.Format.FormatName)
+	}
+
+	media := 0
+	for _, stream := range p.Streams {
+		switch {
+		case stream.CodecType == "video" && !stream.IsAttachedPicture():
+			if !slices.Contains(a.VideoCodecs, stream.CodecName) {
+				return fmt.Errorf("%w: video codec %s is not allowed", ErrUnsupportedMedia, stream.CodecName)
+			}
+			media++
+		case stream.CodecType == "audio":
+			if !slices.Contains(a.AudioCodecs, stream.CodecName) {
+				return fmt.Errorf("%w: audio codec %s is not allowed", ErrUnsupportedMedia, stream.CodecName)
+			}
+			media++
+		}
+	}
+
+	if media == 0 {
+		return fmt.Errorf("%w: no video or audio stream", ErrUnsupportedMedia)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package ffhelp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// WaveformImageColor is the color of the waveform drawn by SaveWaveformImage.
+const WaveformImageColor = "#3ea6ff"
+
+// IsAttachedPicture reports whether the stream is the cover art of the media.
+func (s *Stream) IsAttachedPicture() bool {
+	return s.Disposition["attached_pic"] == 1
+}
+
+// HasVideo reports whether the media has a video stream. The cover art doesn't count.
+func (ff *FFHelp) HasVideo() bool {
+	return ff.videoStream() != nil
+}
+
+// GetDuration returns the duration of the media: of its video, of the format or of its audio.
+func (ff *FFHelp) GetDuration() float64 {
+	if duration := ff.GetVideoDuration(); duration > 0 {
+		return duration
+	}
+	if ff.p.Format.Duration > 0 {
+		return ff.p.Format.Duration
+	}
+
+	for _, stream := range ff.p.Streams {
+		if stream.CodecType == "audio" && stream.Duration > 0 {
+			return stream.Duration
+		}
+	}
+
+	return 0
+}
+
+// AttachedPicture returns the cover art stream, or nil if there is none.
+func (ff *FFHelp) AttachedPicture() *Stream {
+	for i, stream := range ff.p.Streams {
+		if stream.CodecType == "video" && stream.IsAttachedPicture() {
+			return &ff.p.Streams[i]
+		}
+	}
+
+	return nil
+}
+
+// SaveAttachedPicture saves the cover art, fit into the box of the given width and height.
+func (ff *FFHelp) SaveAttachedPicture(outFile string, width, height int) (*os.File, error) {
+	picture := ff.AttachedPicture()
+	if picture == nil {
+		return nil, fmt.Errorf("%s has no attached picture", ff.filename)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outFile), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	fitWidth, fitHeight := fitSize(picture.Width, picture.Height, width, height)
+	err := ff.run(ffmpeg.
+		Input(ff.filename).
+		Output(outFile, ffmpeg.KwArgs{
+			"map":      fmt.Sprintf("0:%d", picture.Index),
+			"frames:v": 1,
+			"vf":       boxFilter(fitWidth, fitHeight, width, height),
+		}).
+		OverWriteOutput())
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(outFile)
+}
+
+// SaveWaveformImage draws the waveform of the whole audio.
+func (ff *FFHelp) SaveWaveformImage(outFile string, width, height int) (*os.File, error) {
+	if !ff.HasAudio() {
+		return nil, ErrNoAudio
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outFile), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	err := ff.run(ffmpeg.
+		Input(ff.filename).
+		Output(outFile, ffmpeg.KwArgs{
+			"filter_complex": fmt.Sprintf(
+				"[0:a:0]aformat=channel_layouts=mono,showwavespic=s=%dx%d:colors=%s",
+				width, height, WaveformImageColor,
+			),
+			"frames:v": 1,
+		}).
+		OverWriteOutput())
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(outFile)
+}
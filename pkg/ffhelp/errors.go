@@ -0,0 +1,97 @@
+package ffhelp
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// StderrTailSize is how many last bytes of the stderr are kept in the Error.
+const StderrTailSize = 4 << 10
+
+// Error is the failure of an ffmpeg or ffprobe process.
+type Error struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// ExitCode is -1 if the process didn't exit by itself, e.g. it couldn't be started.
+	ExitCode int    `json:"exitCode"`
+	Stderr   string `json:"stderr"`
+	Err      error  `json:"-"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Summary()
+	if e.ExitCode == -1 && e.Err != nil {
+		msg = fmt.Sprintf("%s failed: %s", e.Command, e.Err)
+	}
+
+	if line := e.lastLine(); line != "" {
+		msg += ": " + line
+	}
+
+	return msg
+}
+
+// Summary is the message without the arguments and the output of the process,
+// which can contain the paths of the server.
+func (e *Error) Summary() string {
+	if e.ExitCode == -1 {
+		return e.Command + " failed"
+	}
+
+	return fmt.Sprintf("%s exited with code %d", e.Command, e.ExitCode)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) lastLine() string {
+	lines := strings.Split(strings.TrimSpace(e.Stderr), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// run runs the command, returning the *Error if it fails.
+// The stderr of the command is captured unless it is already set.
+func run(cmd *exec.Cmd) error {
+	tail := &tailBuffer{size: StderrTailSize}
+	if cmd.Stderr == nil {
+		cmd.Stderr = tail
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	return &Error{
+		Command:  filepath.Base(cmd.Args[0]),
+		Args:     cmd.Args[1:],
+		ExitCode: exitCode,
+		Stderr:   string(tail.buf),
+		Err:      err,
+	}
+}
+
+// tailBuffer keeps the last size bytes written to it.
+type tailBuffer struct {
+	buf  []byte
+	size int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+
+	return len(p), nil
+}
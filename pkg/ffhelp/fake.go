@@ -0,0 +1,268 @@
+package ffhelp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeRunner is the Runner which records the commands instead of running them.
+// It returns the canned ProbeJSON for every file and synthesizes
+// the outputs of the commands, so FFHelp can be used without ffmpeg.
+type FakeRunner struct {
+	ProbeJSON []byte
+	// Fail, if set, is called before every command and the command fails with its error.
+	Fail func(args []string) error
+
+	mu       sync.Mutex
+	commands [][]string
+	probes   []string
+}
+
+func NewFakeRunner(probeJSON []byte) *FakeRunner {
+	return &FakeRunner{
+		ProbeJSON: probeJSON,
+	}
+}
+
+// Commands returns the args of all the ffmpeg commands run so far.
+func (r *FakeRunner) Commands() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.commands)
+}
+
+// Probes returns the names of all the probed files.
+func (r *FakeRunner) Probes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.probes)
+}
+
+func (r *FakeRunner) Probe(filename string) ([]byte, error) {
+	r.mu.Lock()
+	r.probes = append(r.probes, filename)
+	r.mu.Unlock()
+
+	if r.Fail != nil {
+		if err := r.Fail([]string{filename}); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.ProbeJSON, nil
+}
+
+func (r *FakeRunner) Run(args []string, stdout io.Writer) error {
+	r.mu.Lock()
+	r.commands = append(r.commands, slices.Clone(args))
+	r.mu.Unlock()
+
+	if r.Fail != nil {
+		if err := r.Fail(args); err != nil {
+			return err
+		}
+	}
+
+	p, err := decodeProbe(r.ProbeJSON)
+	if err != nil {
+		return err
+	}
+	duration := (&FFHelp{p: p}).GetDuration()
+
+	cmd := parseFakeArgs(args)
+	if cmd.output == "pipe:1" {
+		return cmd.synthesizePCM(stdout, duration)
+	}
+	if err = cmd.synthesize(duration); err != nil {
+		return err
+	}
+
+	if stdout != nil && cmd.options["progress"] != "" {
+		fmt.Fprintf(stdout, "out_time_us=%d\nprogress=end\n", int64(duration*1e6))
+	}
+
+	return nil
+}
+
+// fakeMP4 and fakeWebP are the headers of the formats, enough to detect their mime types.
+var (
+	fakeMP4  = []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00isomiso2avc1mp41")
+	fakeWebP = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+)
+
+// fakeBareFlags are the options without a value.
+var fakeBareFlags = []string{"y", "n", "an", "vn", "sn", "nostats"}
+
+var (
+	fakeFpsRe   = regexp.MustCompile(`fps=1/([0-9.]+)`)
+	fakeScaleRe = regexp.MustCompile(`scale=(\d+):(\d+)`)
+	fakeTileRe  = regexp.MustCompile(`tile=(\d+)x(\d+)`)
+	fakePadRe   = regexp.MustCompile(`pad=(\d+):(\d+)`)
+)
+
+type fakeCommand struct {
+	options map[string]string
+	output  string
+}
+
+func parseFakeArgs(args []string) *fakeCommand {
+	cmd := &fakeCommand{options: map[string]string{}}
+
+	for i := 0; i < len(args); i++ {
+		name, isOption := strings.CutPrefix(args[i], "-")
+		switch {
+		case !isOption:
+			cmd.output = args[i]
+		case slices.Contains(fakeBareFlags, name) || i == len(args)-1:
+			cmd.options[name] = ""
+		default:
+			cmd.options[name] = args[i+1]
+			i++
+		}
+	}
+
+	return cmd
+}
+
+// synthesize writes the outputs the real command would write.
+func (c *fakeCommand) synthesize(duration float64) error {
+	if c.output == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.output), os.ModePerm); err != nil {
+		return err
+	}
+
+	switch filepath.Ext(c.output) {
+	case ".jpg", ".jpeg":
+		return c.synthesizeImages(duration)
+	case ".m3u8":
+		return c.synthesizeHLS(duration)
+	case ".mpd":
+		if err := os.WriteFile(c.output, []byte("<MPD></MPD>\n"), 0644); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(filepath.Dir(c.output), "init-0.m4s"), []byte("fake"), 0644)
+	case ".mp4":
+		return os.WriteFile(c.output, fakeMP4, 0644)
+	case ".webp":
+		return os.WriteFile(c.output, fakeWebP, 0644)
+	default:
+		return os.WriteFile(c.output, []byte("fake"), 0644)
+	}
+}
+
+func (c *fakeCommand) synthesizeImages(duration float64) error {
+	filter := c.options["vf"]
+
+	width, height := 16, 9
+	if m := fakeScaleRe.FindStringSubmatch(filter); m != nil {
+		width, _ = strconv.Atoi(m[1])
+		height, _ = strconv.Atoi(m[2])
+	}
+
+	if m := fakePadRe.FindStringSubmatch(filter); m != nil {
+		width, _ = strconv.Atoi(m[1])
+		height, _ = strconv.Atoi(m[2])
+	}
+
+	count := 1
+	if m := fakeFpsRe.FindStringSubmatch(filter); m != nil {
+		frameDuration, _ := strconv.ParseFloat(m[1], 64)
+		count = int(math.Ceil(duration / frameDuration))
+	}
+	if m := fakeTileRe.FindStringSubmatch(filter); m != nil {
+		cols, _ := strconv.Atoi(m[1])
+		rows, _ := strconv.Atoi(m[2])
+		width, height = width*cols, height*rows
+		count = (count + cols*rows - 1) / (cols * rows)
+	}
+
+	if !strings.Contains(c.output, "%") {
+		return writeFakeImage(c.output, width, height)
+	}
+
+	start := 1
+	if s, ok := c.options["start_number"]; ok {
+		start, _ = strconv.Atoi(s)
+	}
+	for i := 0; i < count; i++ {
+		if err := writeFakeImage(fmt.Sprintf(c.output, start+i), width, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeCommand) synthesizeHLS(duration float64) error {
+	segment := c.options["hls_segment_filename"]
+	if segment == "" {
+		segment = strings.TrimSuffix(c.output, ".m3u8") + "%d.ts"
+	}
+	segment = fmt.Sprintf(segment, 0)
+
+	if err := os.WriteFile(segment, []byte("fake"), 0644); err != nil {
+		return err
+	}
+
+	playlist := fmt.Sprintf(
+		"#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:%f,\n%s\n#EXT-X-ENDLIST\n",
+		int(math.Ceil(duration)), duration, filepath.Base(segment),
+	)
+
+	return os.WriteFile(c.output, []byte(playlist), 0644)
+}
+
+// synthesizePCM writes the mono s16le square wave of the duration to the stdout.
+func (c *fakeCommand) synthesizePCM(stdout io.Writer, duration float64) error {
+	if stdout == nil {
+		return nil
+	}
+
+	rate, err := strconv.Atoi(c.options["ar"])
+	if err != nil {
+		return fmt.Errorf("unsupported fake audio rate %q", c.options["ar"])
+	}
+
+	chunk := make([]byte, 2*rate)
+	for i := 0; i < rate; i++ {
+		sample := int16(1 << 14)
+		if i%2 == 1 {
+			sample = -sample
+		}
+		binary.LittleEndian.PutUint16(chunk[2*i:], uint16(sample))
+	}
+
+	// a chunk is a second of the audio
+	for left := int(math.Round(duration * float64(rate))); left > 0; left -= rate {
+		if _, err = stdout.Write(chunk[:2*min(left, rate)]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFakeImage(path string, width, height int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+}
@@ -44,16 +44,44 @@ type Stream struct {
 	Duration       float64 `json:"duration" mapstructure:"duration"`
 	RFrameRate     string  `json:"r_frame_rate" mapstructure:"r_frame_rate"`
 	Bitrate        string  `json:"bit_rate" mapstructure:"bit_rate"`
+
+	SampleAspectRatio string            `json:"sample_aspect_ratio" mapstructure:"sample_aspect_ratio"`
+	SideDataList      []SideData        `json:"side_data_list" mapstructure:"side_data_list"`
+	Tags              map[string]string `json:"tags" mapstructure:"tags"`
+	Disposition       map[string]int    `json:"disposition" mapstructure:"disposition"`
+}
+
+type SideData struct {
+	SideDataType string `json:"side_data_type" mapstructure:"side_data_type"`
+	Rotation     int    `json:"rotation" mapstructure:"rotation"`
 }
 
+// ProgressFunc receives the progress of a long operation from 0 to 1.
+type ProgressFunc func(progress float64)
+
 type FFHelp struct {
 	stream   *ffmpeg.Stream
 	p        *Probe
 	filename string
+	runner   Runner
 }
 
 func Input(filename string) (*FFHelp, error) {
-	p, err := probe(filename)
+	return InputWithRunner(filename, NewRunner())
+}
+
+// InputWithRunner is Input, which runs the commands with the runner.
+func InputWithRunner(filename string, runner Runner) (*FFHelp, error) {
+	if runner == nil {
+		runner = NewRunner()
+	}
+
+	metaJson, err := runner.Probe(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodeProbe(metaJson)
 	if err != nil {
 		return nil, err
 	}
@@ -62,17 +90,13 @@ func Input(filename string) (*FFHelp, error) {
 		stream:   ffmpeg.Input(filename),
 		p:        p,
 		filename: filename,
+		runner:   runner,
 	}, nil
 }
 
-func probe(filename string) (*Probe, error) {
-	metaJson, err := ffmpeg.Probe(filename)
-	if err != nil {
-		return nil, err
-	}
-
+func decodeProbe(metaJson []byte) (*Probe, error) {
 	var m map[string]interface{}
-	err = json.Unmarshal([]byte(metaJson), &m)
+	err := json.Unmarshal(metaJson, &m)
 	if err != nil {
 		return nil, err
 	}
@@ -86,28 +110,33 @@ func probe(filename string) (*Probe, error) {
 	return p, nil
 }
 
-func (ff *FFHelp) SplitVideoToThumbnails(output string, frameDuration float64, thumbWidth, thumbHeight int) error {
+func (ff *FFHelp) SplitVideoToThumbnails(output string, frameDuration float64, thumbWidth, thumbHeight int, progress ProgressFunc) error {
 	err := os.MkdirAll(output, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
 	duration := ff.GetVideoDuration()
-	scaleArg := fmt.Sprintf("scale=%d:%d", thumbWidth, thumbHeight)
+	scaleArg := ff.boxFilter(thumbWidth, thumbHeight)
 	i := 0
 	for second := 1.0; second < duration; second = second + frameDuration {
 		imagePath := fmt.Sprintf("%s/img%06d.jpg", output, i)
 
-		ffmpeg.
+		err = ff.run(ffmpeg.
 			Input(ff.filename, ffmpeg.KwArgs{"ss": second}).
 			Output(imagePath, ffmpeg.KwArgs{
 				"vframes": "1",
 				"vf":      scaleArg,
-			}).
-			Silent(true).
-			Run()
+			}))
+		if err != nil {
+			return err
+		}
 
 		i = i + 1
+
+		if progress != nil {
+			progress(second / duration)
+		}
 	}
 
 	return nil
@@ -119,54 +148,56 @@ func (ff *FFHelp) SaveFrame(outFile string, second float64, thumbWidth, thumbHei
 		return nil, err
 	}
 
-	scaleArg := fmt.Sprintf("scale=%d:%d", thumbWidth, thumbHeight)
+	scaleArg := ff.boxFilter(thumbWidth, thumbHeight)
 
-	ffmpeg.
+	err = ff.run(ffmpeg.
 		Input(ff.filename, ffmpeg.KwArgs{"ss": second}).
 		Output(outFile, ffmpeg.KwArgs{
 			"vframes": "1",
 			"vf":      scaleArg,
-		}).
-		Silent(true).
-		Run()
+		}))
+	if err != nil {
+		return nil, err
+	}
 
 	return os.Open(outFile)
 }
 
+// run runs the ffmpeg command of the stream with the runner.
+func (ff *FFHelp) run(stream *ffmpeg.Stream) error {
+	cmd := stream.Silent(true).Compile()
+
+	return ff.runner.Run(cmd.Args[1:], cmd.Stdout)
+}
+
+// GetVideoDuration returns the duration of the video stream,
+// or the duration of the format if the stream has none, e.g. in WebM.
 func (ff *FFHelp) GetVideoDuration() float64 {
-	var duration float64
-	for _, stream := range ff.p.Streams {
-		if stream.CodecType == "video" {
-			duration = stream.Duration
-			break
-		}
+	stream := ff.videoStream()
+	if stream == nil {
+		return 0
+	}
+	if stream.Duration > 0 {
+		return stream.Duration
 	}
 
-	return duration
+	return ff.p.Format.Duration
 }
 
 func (ff *FFHelp) GetVideoWidth() int {
-	var width int
-	for _, stream := range ff.p.Streams {
-		if stream.CodecType == "video" {
-			width = stream.Width
-			break
-		}
+	if stream := ff.videoStream(); stream != nil {
+		return stream.Width
 	}
 
-	return width
+	return 0
 }
 
 func (ff *FFHelp) GetVideoHeight() int {
-	var height int
-	for _, stream := range ff.p.Streams {
-		if stream.CodecType == "video" {
-			height = stream.Height
-			break
-		}
+	if stream := ff.videoStream(); stream != nil {
+		return stream.Height
 	}
 
-	return height
+	return 0
 }
 
 func (ff *FFHelp) Probe() *Probe {
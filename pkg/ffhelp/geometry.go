@@ -0,0 +1,113 @@
+package ffhelp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rotation returns the clockwise rotation of the video in degrees: 0, 90, 180 or 270.
+// ffmpeg applies the rotation while decoding, so the frames have the DisplaySize.
+func (ff *FFHelp) Rotation() int {
+	stream := ff.videoStream()
+	if stream == nil {
+		return 0
+	}
+
+	rotation := 0
+	if r, err := strconv.Atoi(stream.Tags["rotate"]); err == nil {
+		rotation = r
+	}
+	for _, sideData := range stream.SideDataList {
+		if sideData.SideDataType == "Display Matrix" {
+			// the display matrix rotation is counterclockwise
+			rotation = -sideData.Rotation
+		}
+	}
+
+	return ((rotation%360 + 360) % 360) / 90 * 90
+}
+
+// DisplaySize returns the size of the decoded frames, taking the sample aspect ratio
+// and the rotation of the video into account.
+func (ff *FFHelp) DisplaySize() (int, int) {
+	width, height := ff.GetVideoWidth(), ff.GetVideoHeight()
+
+	if stream := ff.videoStream(); stream != nil {
+		if num, den, ok := parseRatio(stream.SampleAspectRatio); ok && num != den {
+			width = width * num / den
+		}
+	}
+
+	if rotation := ff.Rotation(); rotation == 90 || rotation == 270 {
+		width, height = height, width
+	}
+
+	return width, height
+}
+
+// FitSize returns the largest size with the aspect ratio of the video,
+// which fits into the box of the given width and height.
+func (ff *FFHelp) FitSize(boxWidth, boxHeight int) (int, int) {
+	width, height := ff.DisplaySize()
+
+	return fitSize(width, height, boxWidth, boxHeight)
+}
+
+func fitSize(width, height, boxWidth, boxHeight int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return boxWidth, boxHeight
+	}
+
+	if width*boxHeight > height*boxWidth {
+		return boxWidth, max(int(math.Round(float64(boxWidth)*float64(height)/float64(width))), 1)
+	}
+
+	return max(int(math.Round(float64(boxHeight)*float64(width)/float64(height))), 1), boxHeight
+}
+
+// boxFilter scales the video to fit into the box of the given width and height,
+// keeping its aspect ratio, and fills the rest of the box with black:
+// pillarbox for vertical videos and letterbox for the wide ones.
+func (ff *FFHelp) boxFilter(boxWidth, boxHeight int) string {
+	width, height := ff.FitSize(boxWidth, boxHeight)
+
+	return boxFilter(width, height, boxWidth, boxHeight)
+}
+
+func boxFilter(width, height, boxWidth, boxHeight int) string {
+	return fmt.Sprintf(
+		"scale=%d:%d,setsar=1,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black",
+		width, height, boxWidth, boxHeight,
+	)
+}
+
+// videoStream returns the first video stream, which isn't an attached picture.
+func (ff *FFHelp) videoStream() *Stream {
+	for i, stream := range ff.p.Streams {
+		if stream.CodecType == "video" && !stream.IsAttachedPicture() {
+			return &ff.p.Streams[i]
+		}
+	}
+
+	return nil
+}
+
+func parseRatio(s string) (int, int, bool) {
+	a, b, found := strings.Cut(s, ":")
+	if !found {
+		return 0, 0, false
+	}
+
+	num, err := strconv.Atoi(a)
+	if err != nil || num <= 0 {
+		return 0, 0, false
+	}
+	den, err := strconv.Atoi(b)
+	if err != nil || den <= 0 {
+		return 0, 0, false
+	}
+
+	return num, den, true
+}
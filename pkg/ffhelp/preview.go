@@ -0,0 +1,128 @@
+package ffhelp
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Frame is a frame of the video saved to the Path.
+type Frame struct {
+	Time  float64
+	Path  string
+	Score float64
+}
+
+// scoreSamples is the number of pixels sampled along the longest side of an image by ScoreImage.
+const scoreSamples = 160
+
+// PreviewCandidates saves count frames evenly spread over the video,
+// skipping its very start and end, and scores them, see ScoreImage.
+// The frames are returned in the order of their time.
+func (ff *FFHelp) PreviewCandidates(output string, count, width, height int) ([]*Frame, error) {
+	duration := ff.GetVideoDuration()
+
+	var frames []*Frame
+	for i := 0; i < count; i++ {
+		frame := &Frame{
+			Time: duration * float64(i+1) / float64(count+1),
+			Path: filepath.Join(output, fmt.Sprintf("candidate%02d.jpg", i)),
+		}
+
+		file, err := ff.SaveFrame(frame.Path, frame.Time, width, height)
+		if err != nil {
+			return nil, err
+		}
+
+		img, err := jpeg.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		frame.Score = ScoreImage(img)
+		frames = append(frames, frame)
+	}
+
+	return frames, nil
+}
+
+// BestFrame returns the first frame with the highest score.
+func BestFrame(frames []*Frame) *Frame {
+	var best *Frame
+	for _, frame := range frames {
+		if best == nil || frame.Score > best.Score {
+			best = frame
+		}
+	}
+
+	return best
+}
+
+// ScoreFrame scores the JPEG image of the file, see ScoreImage.
+func ScoreFrame(path string) (float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return ScoreImage(img), nil
+}
+
+// ScoreImage rates how good the image is as a preview from 0 to 1.
+// Black and flat images, such as fades and title cards, get a low score,
+// as well as the blurry ones. The score is the ratio of the non-black pixels
+// multiplied by the average of the luminance contrast and the sharpness.
+func ScoreImage(img image.Image) float64 {
+	bounds := img.Bounds()
+	step := max(max(bounds.Dx(), bounds.Dy())/scoreSamples, 1)
+
+	cols, rows := (bounds.Dx()+step-1)/step, (bounds.Dy()+step-1)/step
+	if cols < 3 || rows < 3 {
+		return 0
+	}
+
+	luma := make([]float64, cols*rows)
+	var sum, nonBlack float64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*step, bounds.Min.Y+y*step).RGBA()
+			l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			luma[y*cols+x] = l
+			sum += l
+			if l > 24 {
+				nonBlack++
+			}
+		}
+	}
+
+	n := float64(len(luma))
+	mean := sum / n
+
+	var variance float64
+	for _, l := range luma {
+		variance += (l - mean) * (l - mean)
+	}
+	contrast := math.Min(math.Sqrt(variance/n)/64, 1)
+
+	// the mean absolute laplacian is high for the sharp edges
+	var laplacian float64
+	for y := 1; y < rows-1; y++ {
+		for x := 1; x < cols-1; x++ {
+			i := y*cols + x
+			laplacian += math.Abs(4*luma[i] - luma[i-1] - luma[i+1] - luma[i-cols] - luma[i+cols])
+		}
+	}
+	sharpness := math.Min(laplacian/float64((cols-2)*(rows-2))/32, 1)
+
+	return nonBlack / n * (contrast + sharpness) / 2
+}
@@ -0,0 +1,70 @@
+package ffhelp
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+)
+
+// Runner runs the ffmpeg and ffprobe commands of FFHelp.
+type Runner interface {
+	// Run runs ffmpeg with the args. The stdout of ffmpeg is written to the stdout, if it isn't nil.
+	Run(args []string, stdout io.Writer) error
+	// Probe returns the ffprobe JSON with the format and the streams of the file.
+	Probe(filename string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+// NewRunner returns the Runner of the ffmpeg and ffprobe executables.
+func NewRunner() Runner {
+	return execRunner{}
+}
+
+func (execRunner) Run(args []string, stdout io.Writer) error {
+	cmd := exec.Command("ffmpeg", args...)
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+
+	return run(cmd)
+}
+
+func (execRunner) Probe(filename string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("ffprobe", "-show_format", "-show_streams", "-of", "json", filename)
+	cmd.Stdout = &out
+	if err := run(cmd); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+type limitedRunner struct {
+	runner Runner
+	slots  chan struct{}
+}
+
+// NewLimitedRunner returns the Runner which runs at most n commands of the runner at once.
+// The other commands wait for their turn.
+func NewLimitedRunner(runner Runner, n int) Runner {
+	return &limitedRunner{
+		runner: runner,
+		slots:  make(chan struct{}, max(n, 1)),
+	}
+}
+
+func (r *limitedRunner) Run(args []string, stdout io.Writer) error {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	return r.runner.Run(args, stdout)
+}
+
+func (r *limitedRunner) Probe(filename string) ([]byte, error) {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	return r.runner.Probe(filename)
+}
@@ -0,0 +1,88 @@
+package ffhelp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// TeaserOptions configures CreateTeaser.
+type TeaserOptions struct {
+	// Segments is the number of the clips evenly spaced over the video.
+	Segments int
+	// SegmentDuration is the duration of a single clip in seconds.
+	SegmentDuration float64
+	// Width and Height are the box the teaser fits into, see FitSize.
+	Width  int
+	Height int
+	FPS    int
+}
+
+// CreateTeaser joins the short clips of the video into a silent MP4 teaser
+// and converts it into the animated WebP one.
+func (ff *FFHelp) CreateTeaser(mp4File, webpFile string, opts TeaserOptions) error {
+	if err := os.MkdirAll(filepath.Dir(mp4File), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(webpFile), os.ModePerm); err != nil {
+		return err
+	}
+
+	width, height := ff.FitSize(opts.Width, opts.Height)
+	// libx264 needs the even size
+	width, height = width-width%2, height-height%2
+
+	var clips []*ffmpeg.Stream
+	for _, start := range ff.teaserStarts(opts.Segments, opts.SegmentDuration) {
+		clips = append(clips, ffmpeg.
+			Input(ff.filename, ffmpeg.KwArgs{"ss": start, "t": opts.SegmentDuration}).
+			Video(),
+		)
+	}
+
+	err := ff.run(ffmpeg.
+		Concat(clips).
+		Filter("fps", ffmpeg.Args{fmt.Sprint(opts.FPS)}).
+		Filter("scale", ffmpeg.Args{fmt.Sprintf("%d:%d", width, height)}).
+		Filter("setsar", ffmpeg.Args{"1"}).
+		Output(mp4File, ffmpeg.KwArgs{
+			"an":       "",
+			"c:v":      "libx264",
+			"preset":   "veryfast",
+			"crf":      28,
+			"pix_fmt":  "yuv420p",
+			"movflags": "+faststart",
+		}).
+		OverWriteOutput())
+	if err != nil {
+		return err
+	}
+
+	return ff.run(ffmpeg.
+		Input(mp4File).
+		Output(webpFile, ffmpeg.KwArgs{
+			"c:v":      "libwebp",
+			"loop":     0,
+			"q:v":      60,
+			"lossless": 0,
+		}).
+		OverWriteOutput())
+}
+
+// teaserStarts returns the start of every clip, so the clips are centered
+// in the equal parts of the video. A short video makes a single clip from its start.
+func (ff *FFHelp) teaserStarts(segments int, segmentDuration float64) []float64 {
+	duration := ff.GetVideoDuration()
+	if segments <= 1 || duration <= float64(segments)*segmentDuration {
+		return []float64{0}
+	}
+
+	starts := make([]float64, segments)
+	for i := range starts {
+		starts[i] = max(duration*(float64(i)+0.5)/float64(segments)-segmentDuration/2, 0)
+	}
+
+	return starts
+}
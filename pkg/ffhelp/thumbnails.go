@@ -0,0 +1,72 @@
+package ffhelp
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ThumbnailsOptions configures ExtractThumbnails.
+type ThumbnailsOptions struct {
+	// FrameDuration is the number of seconds between the thumbnails.
+	FrameDuration float64
+	// Width and Height are the exact size of the thumbnails, see FitSize.
+	Width  int
+	Height int
+	// KeyframesOnly decodes only the keyframes of the video, which is much faster,
+	// but every thumbnail is the closest keyframe before its time.
+	KeyframesOnly bool
+	// Cols and Rows tile the thumbnails into sheets, if both are set.
+	Cols int
+	Rows int
+}
+
+func (o ThumbnailsOptions) tiled() bool {
+	return o.Cols > 0 && o.Rows > 0
+}
+
+// ExtractThumbnails decodes the video once, taking a thumbnail every FrameDuration seconds.
+// The thumbnails are named img%06d.jpg like the ones of SplitVideoToThumbnails,
+// or sheet%06d.jpg when they are tiled into sheets.
+func (ff *FFHelp) ExtractThumbnails(output string, opts ThumbnailsOptions, progress ProgressFunc) error {
+	err := os.MkdirAll(output, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	filter := fmt.Sprintf("fps=1/%g,scale=%d:%d,setsar=1", opts.FrameDuration, opts.Width, opts.Height)
+	name := "img%06d.jpg"
+	if opts.tiled() {
+		filter += fmt.Sprintf(",tile=%dx%d", opts.Cols, opts.Rows)
+		name = "sheet%06d.jpg"
+	}
+
+	inputArgs := ffmpeg.KwArgs{}
+	if opts.KeyframesOnly {
+		inputArgs["skip_frame"] = "nokey"
+	}
+
+	w := ff.progressWriter(0, 1, progress)
+	err = ff.run(ffmpeg.
+		Input(ff.filename, inputArgs).
+		Output(filepath.Join(output, name), ffmpeg.KwArgs{
+			"vf":           filter,
+			"an":           "",
+			"start_number": 0,
+			"q:v":          3,
+		}).
+		GlobalArgs("-progress", "pipe:1", "-nostats").
+		OverWriteOutput().
+		WithOutput(w))
+	w.Close()
+
+	return err
+}
+
+// ThumbnailsCount returns the number of the thumbnails taken every frameDuration seconds.
+func (ff *FFHelp) ThumbnailsCount(frameDuration float64) int {
+	return int(math.Ceil(ff.GetVideoDuration() / frameDuration))
+}
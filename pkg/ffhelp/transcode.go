@@ -0,0 +1,253 @@
+package ffhelp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Rendition is a single quality of the adaptive stream.
+type Rendition struct {
+	Name         string `json:"name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	VideoBitrate int    `json:"videoBitrate"`
+	AudioBitrate int    `json:"audioBitrate"`
+}
+
+// DefaultRenditions is the H.264 ladder from the highest quality to the lowest.
+var DefaultRenditions = []Rendition{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000_000, AudioBitrate: 192_000},
+	{Name: "720p", Height: 720, VideoBitrate: 2800_000, AudioBitrate: 128_000},
+	{Name: "480p", Height: 480, VideoBitrate: 1400_000, AudioBitrate: 128_000},
+	{Name: "360p", Height: 360, VideoBitrate: 800_000, AudioBitrate: 96_000},
+}
+
+const (
+	HLSMasterPlaylist = "master.m3u8"
+	HLSMediaPlaylist  = "index.m3u8"
+	DASHManifest      = "manifest.mpd"
+
+	// hlsCodecs is H.264 Main profile level 4.0 with AAC-LC
+	hlsCodecs      = "avc1.4d4028,mp4a.40.2"
+	hlsVideoCodecs = "avc1.4d4028"
+)
+
+// Renditions returns the renditions of the ladder which don't upscale the video.
+// The lowest rendition is always kept, so a small video still gets one.
+// The height of the ladder is the short side of the video, so a vertical video
+// gets the same quality, and the other side is computed from the aspect ratio.
+func (ff *FFHelp) Renditions(ladder []Rendition) []Rendition {
+	width, height := ff.DisplaySize()
+	short, long := min(width, height), max(width, height)
+
+	var renditions []Rendition
+	for i, r := range ladder {
+		if r.Height > short && i < len(ladder)-1 {
+			continue
+		}
+
+		size := min(r.Height, short-short%2)
+		r.Width, r.Height = evenRound(float64(long)*float64(size)/float64(short)), size
+		if height > width {
+			r.Width, r.Height = r.Height, r.Width
+		}
+
+		renditions = append(renditions, r)
+	}
+
+	return renditions
+}
+
+// Transcode encodes the video to H.264 + AAC MP4 files of the given renditions,
+// named after them in the output directory.
+func (ff *FFHelp) Transcode(output string, renditions []Rendition, progress ProgressFunc) error {
+	err := os.MkdirAll(output, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range renditions {
+		w := ff.progressWriter(i, len(renditions), progress)
+		err = ff.run(ffmpeg.
+			Input(ff.filename).
+			Output(ff.RenditionPath(output, r), ffmpeg.KwArgs{
+				"vf":           fmt.Sprintf("scale=%d:%d,setsar=1", r.Width, r.Height),
+				"c:v":          "libx264",
+				"preset":       "veryfast",
+				"profile:v":    "main",
+				"level":        "4.0",
+				"pix_fmt":      "yuv420p",
+				"b:v":          r.VideoBitrate,
+				"maxrate":      r.VideoBitrate * 107 / 100,
+				"bufsize":      r.VideoBitrate * 3 / 2,
+				"g":            48,
+				"keyint_min":   48,
+				"sc_threshold": 0,
+				"c:a":          "aac",
+				"b:a":          r.AudioBitrate,
+				"ac":           2,
+				"movflags":     "+faststart",
+			}).
+			GlobalArgs("-progress", "pipe:1", "-nostats").
+			OverWriteOutput().
+			WithOutput(w))
+		w.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ff *FFHelp) RenditionPath(output string, r Rendition) string {
+	return filepath.Join(output, r.Name+".mp4")
+}
+
+// CreateHLS segments the transcoded renditions into HLS media playlists
+// and writes the master playlist referencing them.
+func (ff *FFHelp) CreateHLS(input, output string, renditions []Rendition, segmentDuration int) error {
+	for _, r := range renditions {
+		dir := filepath.Join(output, r.Name)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+
+		err := ff.run(ffmpeg.
+			Input(ff.RenditionPath(input, r)).
+			Output(filepath.Join(dir, HLSMediaPlaylist), ffmpeg.KwArgs{
+				"c":                    "copy",
+				"f":                    "hls",
+				"hls_time":             segmentDuration,
+				"hls_playlist_type":    "vod",
+				"hls_segment_filename": filepath.Join(dir, "seg%05d.ts"),
+			}).
+			OverWriteOutput())
+		if err != nil {
+			return err
+		}
+	}
+
+	return ff.writeHLSMaster(filepath.Join(output, HLSMasterPlaylist), renditions)
+}
+
+// CreateDASH packages the transcoded renditions into fragmented MP4 segments
+// with a single MPD manifest. Video renditions make up one adaptation set,
+// the audio of the highest rendition makes up the other.
+func (ff *FFHelp) CreateDASH(input, output string, renditions []Rendition, segmentDuration int) error {
+	if err := os.MkdirAll(output, os.ModePerm); err != nil {
+		return err
+	}
+
+	var streams []*ffmpeg.Stream
+	for _, r := range renditions {
+		streams = append(streams, ffmpeg.Input(ff.RenditionPath(input, r)).Video())
+	}
+
+	adaptationSets := "id=0,streams=v"
+	if ff.HasAudio() && len(renditions) > 0 {
+		streams = append(streams, ffmpeg.Input(ff.RenditionPath(input, renditions[0])).Audio())
+		adaptationSets += " id=1,streams=a"
+	}
+
+	return ff.run(ffmpeg.
+		Output(streams, filepath.Join(output, DASHManifest), ffmpeg.KwArgs{
+			"c":               "copy",
+			"f":               "dash",
+			"seg_duration":    segmentDuration,
+			"use_template":    1,
+			"use_timeline":    1,
+			"adaptation_sets": adaptationSets,
+			"init_seg_name":   "init-$RepresentationID$.m4s",
+			"media_seg_name":  "chunk-$RepresentationID$-$Number%05d$.m4s",
+		}).
+		OverWriteOutput())
+}
+
+func (ff *FFHelp) writeHLSMaster(outFile string, renditions []Rendition) error {
+	file, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	codecs := hlsVideoCodecs
+	if ff.HasAudio() {
+		codecs = hlsCodecs
+	}
+
+	w := bufio.NewWriter(file)
+	w.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		fmt.Fprintf(w,
+			"#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\"\n%s/%s\n",
+			r.VideoBitrate+r.AudioBitrate, r.Width, r.Height, codecs, r.Name, HLSMediaPlaylist,
+		)
+	}
+
+	return w.Flush()
+}
+
+func (ff *FFHelp) HasAudio() bool {
+	for _, stream := range ff.p.Streams {
+		if stream.CodecType == "audio" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// progressWriter parses the output of "-progress pipe:1" of the step-th of the total steps.
+// Close waits until all the written progress is reported.
+func (ff *FFHelp) progressWriter(step, total int, progress ProgressFunc) io.WriteCloser {
+	r, w := io.Pipe()
+	pw := &progressPipe{PipeWriter: w, done: make(chan struct{})}
+
+	duration := ff.GetVideoDuration()
+	go func() {
+		defer close(pw.done)
+		defer r.Close()
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			value, found := strings.CutPrefix(scanner.Text(), "out_time_us=")
+			if !found || progress == nil || duration <= 0 {
+				continue
+			}
+
+			us, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			p := min(max(us/1e6/duration, 0), 1)
+			progress((float64(step) + p) / float64(total))
+		}
+	}()
+
+	return pw
+}
+
+type progressPipe struct {
+	*io.PipeWriter
+	done chan struct{}
+}
+
+func (p *progressPipe) Close() error {
+	err := p.PipeWriter.Close()
+	<-p.done
+	return err
+}
+
+func evenRound(f float64) int {
+	i := int(f + 0.5)
+	return i - i%2
+}
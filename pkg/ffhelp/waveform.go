@@ -0,0 +1,111 @@
+package ffhelp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// PeaksSampleRate is the rate the audio is resampled to before computing its peaks.
+const PeaksSampleRate = 8000
+
+var ErrNoAudio = errors.New("media has no audio stream")
+
+// Peaks is the waveform of the audio in the JSON format of audiowaveform,
+// which is supported by peaks.js. Data holds the min and max pairs of the 8-bit samples.
+type Peaks struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// Peaks decodes the audio of the media, mixed down to mono,
+// and computes the min and max of every samplesPerPixel samples.
+func (ff *FFHelp) Peaks(samplesPerPixel int) (*Peaks, error) {
+	if !ff.HasAudio() {
+		return nil, ErrNoAudio
+	}
+
+	w := &peaksWriter{
+		samplesPerPixel: samplesPerPixel,
+		min:             math.MaxInt16,
+		max:             math.MinInt16,
+	}
+
+	err := ff.run(ffmpeg.
+		Input(ff.filename).
+		Output("pipe:1", ffmpeg.KwArgs{
+			"map": "0:a:0",
+			"ac":  1,
+			"ar":  PeaksSampleRate,
+			"f":   "s16le",
+		}).
+		WithOutput(w))
+	if err != nil {
+		return nil, err
+	}
+	w.flush()
+
+	return &Peaks{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      PeaksSampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+		Length:          len(w.data) / 2,
+		Data:            w.data,
+	}, nil
+}
+
+// peaksWriter computes the peaks of the signed 16-bit little-endian samples written to it.
+type peaksWriter struct {
+	samplesPerPixel int
+	data            []int8
+
+	// rest is the odd byte of the last write
+	rest  []byte
+	count int
+	min   int16
+	max   int16
+}
+
+func (w *peaksWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(w.rest) > 0 {
+		p = append(w.rest, p...)
+		w.rest = nil
+	}
+
+	for ; len(p) >= 2; p = p[2:] {
+		sample := int16(binary.LittleEndian.Uint16(p))
+		w.min = min(w.min, sample)
+		w.max = max(w.max, sample)
+
+		w.count++
+		if w.count == w.samplesPerPixel {
+			w.flush()
+		}
+	}
+	if len(p) > 0 {
+		w.rest = []byte{p[0]}
+	}
+
+	return n, nil
+}
+
+func (w *peaksWriter) flush() {
+	if w.count == 0 {
+		return
+	}
+
+	w.data = append(w.data, int8(w.min>>8), int8(w.max>>8))
+	w.count = 0
+	w.min = math.MaxInt16
+	w.max = math.MinInt16
+}